@@ -39,8 +39,18 @@ type NacosSpec struct {
 	NodeSelector   map[string]string       `json:"nodeSelector,omitempty" protobuf:"bytes,7,rep,name=nodeSelector"`
 	LivenessProbe  *v1.Probe               `json:"livenessProbe,omitempty" protobuf:"bytes,10,opt,name=livenessProbe"`
 	ReadinessProbe *v1.Probe               `json:"readinessProbe,omitempty" protobuf:"bytes,11,opt,name=readinessProbe"`
-	Env            []v1.EnvVar             `json:"env,omitempty" patchStrategy:"merge" patchMergeKey:"name" protobuf:"bytes,7,rep,name=env"`
-	MysqlInitImage string                  `json:"mysqlInitImage,omitempty"`
+	// 为空时使用默认的startupProbe，等待nacos启动完成后再执行liveness和readiness
+	StartupProbe   *v1.Probe   `json:"startupProbe,omitempty"`
+	Env            []v1.EnvVar `json:"env,omitempty" patchStrategy:"merge" patchMergeKey:"name" protobuf:"bytes,7,rep,name=env"`
+	MysqlInitImage string      `json:"mysqlInitImage,omitempty"`
+	// nacos版本，例如2.0.3，为空时从image的tag中解析；tag无法解析时按2.x处理
+	Version string `json:"version,omitempty"`
+	// 服务端口，默认8848；raft端口和2.x的grpc端口按nacos的规则相对这个端口偏移
+	// +kubebuilder:validation:Minimum=1001
+	// +kubebuilder:validation:Maximum=64534
+	Port int32 `json:"port,omitempty"`
+	// 访问路径，默认/nacos，根路径为/
+	ContextPath string `json:"contextPath,omitempty"`
 
 	// 自定义配置
 	// 部署模式
@@ -49,6 +59,71 @@ type NacosSpec struct {
 	Volume   Storage  `json:"volume,omitempty"`
 	// 配置文件
 	Config string `json:"config,omitempty"`
+	// 监控配置，依赖prometheus-operator的crd
+	Monitoring Monitoring `json:"monitoring,omitempty"`
+	// 健康检查的周期，例如1m，为空时使用operator的--health-check-interval，0s表示不定期检查
+	HealthCheckInterval string `json:"healthCheckInterval,omitempty"`
+	// 删除cr时对数据的处理策略，默认Retain
+	// +kubebuilder:validation:Enum=Retain;Delete;Snapshot
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Snapshot策略下删除前最后一次备份的存储位置
+	FinalBackup *BackupStorage `json:"finalBackup,omitempty"`
+	// 鉴权配置
+	Auth Auth `json:"auth,omitempty"`
+	// https配置
+	TLS TLS `json:"tls,omitempty"`
+}
+
+type TLS struct {
+	// 开启后nacos只提供https服务，probe、service和operator的访问都切换为https
+	Enabled bool `json:"enabled,omitempty"`
+	// 证书所在的secret，包含keystore.p12和ca.crt；配置了issuerRef时为空，使用cert-manager生成的<name>-tls
+	SecretName string `json:"secretName,omitempty"`
+	// keystore.p12的密码，使用secretName时必填
+	KeystorePasswordSecretRef *v1.SecretKeySelector `json:"keystorePasswordSecretRef,omitempty"`
+	// cert-manager的Issuer，operator创建Certificate签发证书
+	IssuerRef *IssuerRef `json:"issuerRef,omitempty"`
+}
+
+type IssuerRef struct {
+	Name string `json:"name"`
+	// 默认Issuer
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	Kind string `json:"kind,omitempty"`
+	// 默认cert-manager.io
+	Group string `json:"group,omitempty"`
+}
+
+type Auth struct {
+	// 开启nacos.core.auth.enabled，operator使用管理员nacos访问
+	Enabled bool `json:"enabled,omitempty"`
+	// 管理员nacos的密码，修改后operator会重新设置；为空时随机生成，保存在<name>-auth中
+	AdminPasswordSecretRef *v1.SecretKeySelector `json:"adminPasswordSecretRef,omitempty"`
+	// token有效期，默认18000秒
+	TokenExpireSeconds int32 `json:"tokenExpireSeconds,omitempty"`
+	// operator访问nacos使用的账号，secret中包含username和password，为空时使用管理员nacos；
+	// 通过spec.config开启鉴权时也需要配置
+	CredentialsSecretRef *v1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+}
+
+type DeletionPolicy string
+
+const (
+	// 保留pvc和mysql中的数据
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// 删除pvc，mysql模式下删除nacos创建的表
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// 先备份到finalBackup，备份成功后按照Delete处理，失败时保留数据
+	DeletionPolicySnapshot DeletionPolicy = "Snapshot"
+)
+
+type Monitoring struct {
+	// 生成ServiceMonitor和PrometheusRule
+	Enabled bool `json:"enabled,omitempty"`
+	// 抓取间隔，默认30s
+	Interval string `json:"interval,omitempty"`
+	// 添加到ServiceMonitor和PrometheusRule上的label，用于匹配prometheus的selector
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 type Storage struct {
@@ -58,26 +133,46 @@ type Storage struct {
 }
 
 type Database struct {
-	TypeDatabase  string `json:"type,omitempty" patchStrategy:"merge" patchMergeKey:"name" protobuf:"bytes,7,rep,name=type"`
-	MysqlHost     string `json:"mysqlHost,omitempty"`
-	MysqlPort     string `json:"mysqlPort,omitempty"`
-	MysqlDb       string `json:"mysqlDb,omitempty"`
-	MysqlUser     string `json:"mysqlUser,omitempty"`
+	TypeDatabase string `json:"type,omitempty" patchStrategy:"merge" patchMergeKey:"name" protobuf:"bytes,7,rep,name=type"`
+	MysqlHost    string `json:"mysqlHost,omitempty"`
+	MysqlPort    string `json:"mysqlPort,omitempty"`
+	MysqlDb      string `json:"mysqlDb,omitempty"`
+	// Deprecated: 使用userSecretRef，未配置userSecretRef时用于初始化operator生成的secret
+	MysqlUser string `json:"mysqlUser,omitempty"`
+	// Deprecated: 使用passwordSecretRef，未配置passwordSecretRef时用于初始化operator生成的secret
 	MysqlPassword string `json:"mysqlPassword,omitempty"`
+	// mysql用户名所在的secret，为空时使用operator生成的secret
+	UserSecretRef *v1.SecretKeySelector `json:"userSecretRef,omitempty"`
+	// mysql密码所在的secret，为空时使用operator生成的secret，密码随机生成
+	PasswordSecretRef *v1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
 }
 
 // NacosStatus defines the observed state of Nacos
 type NacosStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	// 记录实例状态
+	// 标准的condition，类型为Ready、Available、Progressing、Degraded和DatabaseInitialized
+	// +listType=map
+	// +listMapKey=type
 	Conditions []Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,2,rep,name=conditions"`
+	// 最近一次处理的cr的generation
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// 集群成员，以第一个ready的pod看到的集群信息为准
+	Members []Member `json:"members,omitempty"`
+	// 每个raft group的leader
+	RaftGroups []RaftGroupStatus `json:"raftGroups,omitempty"`
 	// 记录事件
 	Event []Event `json:"event,omitempty" protobuf:"bytes,4,opt,name=event"`
 	// 运行状态，主要根据这个字段用来判断是否正常
 	Phase Phase `json:"phase,omitempty"`
 
 	Version string `json:"version,omitempty"`
+
+	// 已经加入集群的成员数，扩缩容时逐个向spec.replicas靠拢
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// 当前故障的自愈记录，恢复Running后清空
+	Heal *HealStatus `json:"heal,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -111,35 +206,79 @@ func init() {
 	SchemeBuilder.Register(&Nacos{}, &NacosList{})
 }
 
-// 状况
+// Condition 与metav1.Condition的结构相同，当前依赖的apimachinery版本中还没有metav1.Condition
 type Condition struct {
-	// Type is the type of the condition.
-	// More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#pod-conditions
-	Type string `json:"type" protobuf:"bytes,1,opt,name=type,casttype=PodConditionType"`
-	// Status is the status of the condition.
-	// Can be True, False, Unknown.
-	// More info: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#pod-conditions
-	Status string `json:"status" protobuf:"bytes,2,opt,name=status,casttype=ConditionStatus"`
-	// Last time we probed the condition.
-	// +optional
-	//LastProbeTime metav1.Time `json:"lastProbeTime,omitempty" protobuf:"bytes,3,opt,name=lastProbeTime"`
-	// Last time the condition transitioned from one status to another.
-	// +optional
-	//LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty" protobuf:"bytes,4,opt,name=lastTransitionTime"`
-	// Unique, one-word, CamelCase reason for the condition's last transition.
+	// condition的类型，例如Ready
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=316
+	Type string `json:"type" protobuf:"bytes,1,opt,name=type"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	Status metav1.ConditionStatus `json:"status" protobuf:"bytes,2,opt,name=status"`
+	// 设置condition时cr的generation
 	// +optional
-	Reason string `json:"reason,omitempty" protobuf:"bytes,5,opt,name=reason"`
-	// Human-readable message indicating details about last transition.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty" protobuf:"varint,3,opt,name=observedGeneration"`
+	// status最近一次变化的时间
+	// +kubebuilder:validation:Required
+	LastTransitionTime metav1.Time `json:"lastTransitionTime" protobuf:"bytes,4,opt,name=lastTransitionTime"`
+	// 驼峰格式的原因
+	// +kubebuilder:validation:Required
+	Reason string `json:"reason" protobuf:"bytes,5,opt,name=reason"`
+	// 可读的详细信息
 	// +optional
 	Message string `json:"message,omitempty" protobuf:"bytes,6,opt,name=message"`
+}
 
-	Instance string `json:"instance,omitempty" protobuf:"bytes,4,opt,name=instance"`
+// condition类型
+const (
+	// 集群所有检查都已通过
+	ConditionReady = "Ready"
+	// ready的pod达到多数派，可以对外提供服务
+	ConditionAvailable = "Available"
+	// 正在创建、滚动更新或者扩缩容
+	ConditionProgressing = "Progressing"
+	// 有pod未ready或者检查失败
+	ConditionDegraded = "Degraded"
+	// mysql初始化job已经完成，内置数据库始终为True
+	ConditionDatabaseInitialized = "DatabaseInitialized"
+	// 存储配置与statefulset中不能修改的volumeClaimTemplates不一致，statefulset不再更新
+	ConditionSpecDrifted = "SpecDrifted"
+)
 
-	HostIP string `json:"hostIP,omitempty" protobuf:"bytes,4,opt,name=hostIP"`
+// Member 集群成员
+type Member struct {
+	// 成员地址，ip:port或者域名:port
+	Address string `json:"address"`
+	// 成员对应的pod
+	PodName string `json:"podName,omitempty"`
+	// 在主raft group中的角色，leader或follower
+	Role string `json:"role,omitempty"`
+	// 主raft group的term
+	RaftTerm int `json:"raftTerm,omitempty"`
+	// nacos上报的状态，例如UP、DOWN、SUSPICIOUS
+	State string `json:"state,omitempty"`
+	// 成员最近一次上报的时间
+	LastRefreshTime metav1.Time `json:"lastRefreshTime,omitempty"`
+}
 
-	PodName string `json:"podName,omitempty" protobuf:"bytes,4,opt,name=nodeName"`
+// RaftGroupStatus raft group的leader
+type RaftGroupStatus struct {
+	Name   string `json:"name"`
+	Leader string `json:"leader,omitempty"`
+	// leader对应的pod
+	PodName string `json:"podName,omitempty"`
+}
 
-	NodeName string `json:"nodeName,omitempty" protobuf:"bytes,4,opt,name=nodeName"`
+// HealStatus 自愈记录，保存在status中，operator重启后仍然限制自愈次数
+type HealStatus struct {
+	// 正在处理的故障码
+	Code int `json:"code"`
+	// 已经尝试的次数
+	Attempts int `json:"attempts"`
+	// 最近一次自愈的时间
+	LastAttemptTime metav1.Time `json:"lastAttemptTime,omitempty"`
+	// 已经达到最大次数，需要人工介入
+	Exhausted bool `json:"exhausted,omitempty"`
 }
 
 // 事件
@@ -167,4 +306,37 @@ const (
 	PhaseCreating Phase = "Creating"
 	PhaseFailed   Phase = "Failed"
 	PhaseScale    Phase = "Scaling"
+	PhaseUpdating Phase = "Updating"
 )
+
+// SetCondition 按类型设置condition，status变化时才更新lastTransitionTime，行为与meta.SetStatusCondition相同
+func (s *NacosStatus) SetCondition(condition Condition) {
+	if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	for i := range s.Conditions {
+		existing := &s.Conditions[i]
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status != condition.Status {
+			existing.Status = condition.Status
+			existing.LastTransitionTime = condition.LastTransitionTime
+		}
+		existing.Reason = condition.Reason
+		existing.Message = condition.Message
+		existing.ObservedGeneration = condition.ObservedGeneration
+		return
+	}
+	s.Conditions = append(s.Conditions, condition)
+}
+
+// FindCondition 返回指定类型的condition，不存在时返回nil
+func (s *NacosStatus) FindCondition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
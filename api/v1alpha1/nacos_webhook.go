@@ -0,0 +1,392 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bufio"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// 部署模式
+const (
+	TypeStandalone = "standalone"
+	TypeCluster    = "cluster"
+)
+
+// 数据库类型
+const (
+	DatabaseEmbedded = "embedded"
+	DatabaseMysql    = "mysql"
+)
+
+// 默认的服务端口和访问路径
+const (
+	DefaultPort        = 8848
+	DefaultContextPath = "/nacos"
+)
+
+// 内置数据库的集群模式依赖raft，至少需要3个节点
+const MinEmbeddedClusterReplicas = 3
+
+// prometheus的duration格式，例如30s、1m
+var prometheusDuration = regexp.MustCompile(`^([0-9]+(ms|s|m|h|d|w|y))+$`)
+
+// nacos版本号，允许v前缀，例如2.0.3、v1.4.1
+var versionPattern = regexp.MustCompile(`^v?([0-9]+)(\.[0-9]+)*`)
+
+// log is for logging in this package.
+var nacoslog = logf.Log.WithName("nacos-resource")
+
+func (r *Nacos) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-nacos-io-v1alpha1-nacos,mutating=true,failurePolicy=fail,groups=nacos.io,resources=nacos,verbs=create;update,versions=v1alpha1,name=mnacos.kb.io
+
+var _ webhook.Defaulter = &Nacos{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Nacos) Default() {
+	nacoslog.V(1).Info("default", "name", r.Name)
+
+	if r.Spec.Type == "" {
+		r.Spec.Type = TypeStandalone
+	}
+	if r.Spec.Replicas == nil {
+		replicas := int32(1)
+		if r.Spec.Type == TypeCluster {
+			replicas = MinEmbeddedClusterReplicas
+		}
+		r.Spec.Replicas = &replicas
+	}
+
+	// 默认设置内置数据库
+	if r.Spec.Database.TypeDatabase == "" {
+		r.Spec.Database.TypeDatabase = DatabaseEmbedded
+	}
+	// mysql设置默认值，host必须由用户指定，账号密码由secret提供
+	if r.Spec.Database.TypeDatabase == DatabaseMysql {
+		if r.Spec.Database.MysqlDb == "" {
+			r.Spec.Database.MysqlDb = "nacos"
+		}
+		if r.Spec.Database.MysqlPort == "" {
+			r.Spec.Database.MysqlPort = "3306"
+		}
+	}
+	if r.Spec.DeletionPolicy == "" {
+		r.Spec.DeletionPolicy = DeletionPolicyRetain
+	}
+	if r.Spec.Port == 0 {
+		r.Spec.Port = DefaultPort
+	}
+	if r.Spec.ContextPath == "" {
+		r.Spec.ContextPath = DefaultContextPath
+	}
+	if ref := r.Spec.TLS.IssuerRef; ref != nil {
+		if ref.Kind == "" {
+			ref.Kind = "Issuer"
+		}
+		if ref.Group == "" {
+			ref.Group = "cert-manager.io"
+		}
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-nacos-io-v1alpha1-nacos,mutating=false,failurePolicy=fail,groups=nacos.io,resources=nacos,versions=v1alpha1,name=vnacos.kb.io
+
+var _ webhook.Validator = &Nacos{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Nacos) ValidateCreate() error {
+	nacoslog.V(1).Info("validate create", "name", r.Name)
+	return r.toAggregate(r.ValidateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Nacos) ValidateUpdate(old runtime.Object) error {
+	nacoslog.V(1).Info("validate update", "name", r.Name)
+	// 删除中的对象只会移除finalizer，spec未变化的更新(例如修改label)不校验，避免规则收紧后无法删除或打标签
+	if r.DeletionTimestamp != nil {
+		return nil
+	}
+	oldNacos, ok := old.(*Nacos)
+	if ok && reflect.DeepEqual(oldNacos.Spec, r.Spec) {
+		return nil
+	}
+	return r.toAggregate(r.ValidateSpec())
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Nacos) ValidateDelete() error {
+	return nil
+}
+
+// ValidateSpec 校验spec中的字段组合，webhook和operator自身共用
+func (r *Nacos) ValidateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	switch r.Spec.Type {
+	case TypeStandalone, TypeCluster:
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("type"), r.Spec.Type, []string{TypeStandalone, TypeCluster}))
+	}
+
+	if r.Spec.Replicas != nil && *r.Spec.Replicas < 1 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *r.Spec.Replicas, "must be greater than 0"))
+	}
+
+	dbPath := specPath.Child("database")
+	switch r.Spec.Database.TypeDatabase {
+	case DatabaseEmbedded:
+		if r.Spec.Type == TypeCluster && r.Spec.Replicas != nil && *r.Spec.Replicas < MinEmbeddedClusterReplicas {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *r.Spec.Replicas,
+				fmt.Sprintf("cluster mode with embedded storage requires at least %d replicas", MinEmbeddedClusterReplicas)))
+		}
+	case DatabaseMysql:
+		if r.Spec.Database.MysqlHost == "" {
+			allErrs = append(allErrs, field.Required(dbPath.Child("mysqlHost"), "mysqlHost is required when database type is mysql"))
+		}
+		allErrs = append(allErrs, validateSecretKeySelector(dbPath.Child("userSecretRef"), r.Spec.Database.UserSecretRef)...)
+		allErrs = append(allErrs, validateSecretKeySelector(dbPath.Child("passwordSecretRef"), r.Spec.Database.PasswordSecretRef)...)
+	default:
+		allErrs = append(allErrs, field.NotSupported(dbPath.Child("type"), r.Spec.Database.TypeDatabase, []string{DatabaseEmbedded, DatabaseMysql}))
+	}
+
+	if err := ValidateProperties(r.Spec.Config); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("config"), r.Spec.Config, err.Error()))
+	}
+
+	switch r.Spec.DeletionPolicy {
+	case "", DeletionPolicyRetain, DeletionPolicyDelete:
+	case DeletionPolicySnapshot:
+		if r.Spec.FinalBackup == nil {
+			allErrs = append(allErrs, field.Required(specPath.Child("finalBackup"), "finalBackup is required when deletionPolicy is Snapshot"))
+		} else if (r.Spec.FinalBackup.PVC == nil) == (r.Spec.FinalBackup.S3 == nil) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("finalBackup"), "", "exactly one of pvc and s3 is required"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("deletionPolicy"), r.Spec.DeletionPolicy,
+			[]string{string(DeletionPolicyRetain), string(DeletionPolicyDelete), string(DeletionPolicySnapshot)}))
+	}
+
+	if interval := r.Spec.Monitoring.Interval; interval != "" && !prometheusDuration.MatchString(interval) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("monitoring", "interval"), interval, "must be a prometheus duration such as 30s"))
+	}
+
+	if interval := r.Spec.HealthCheckInterval; interval != "" {
+		if d, err := time.ParseDuration(interval); err != nil || d < 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("healthCheckInterval"), interval, "must be a non-negative duration such as 1m"))
+		}
+	}
+
+	if cp := r.Spec.ContextPath; cp != "" && (!strings.HasPrefix(cp, "/") || strings.ContainsAny(cp, " ?#")) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("contextPath"), cp, "must start with / and must not contain spaces, ? or #"))
+	}
+
+	if r.Spec.Version != "" && !versionPattern.MatchString(r.Spec.Version) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("version"), r.Spec.Version, "must be a version such as 2.0.3"))
+	}
+
+	// 证书来自用户的secret或者cert-manager，二选一
+	if tls := r.Spec.TLS; tls.Enabled {
+		tlsPath := specPath.Child("tls")
+		if (tls.SecretName == "") == (tls.IssuerRef == nil) {
+			allErrs = append(allErrs, field.Invalid(tlsPath, "", "exactly one of secretName and issuerRef is required"))
+		}
+		if tls.SecretName != "" && tls.KeystorePasswordSecretRef == nil {
+			allErrs = append(allErrs, field.Required(tlsPath.Child("keystorePasswordSecretRef"), "keystorePasswordSecretRef is required when secretName is set"))
+		}
+		if tls.IssuerRef != nil && tls.IssuerRef.Name == "" {
+			allErrs = append(allErrs, field.Required(tlsPath.Child("issuerRef", "name"), ""))
+		}
+		allErrs = append(allErrs, validateSecretKeySelector(tlsPath.Child("keystorePasswordSecretRef"), tls.KeystorePasswordSecretRef)...)
+	}
+	return allErrs
+}
+
+// MajorVersion 优先使用spec.version，其次为image的tag，无法解析时返回0
+func (r *Nacos) MajorVersion() int {
+	version := r.Spec.Version
+	if version == "" {
+		version = imageTag(r.Spec.Image)
+	}
+	match := versionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return 0
+	}
+	major, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
+// ServerPort 服务端口，未设置时为默认的8848
+func (r *Nacos) ServerPort() int32 {
+	if r.Spec.Port == 0 {
+		return DefaultPort
+	}
+	return r.Spec.Port
+}
+
+// ServerContextPath 访问路径，去掉结尾的/，根路径返回空字符串
+func (r *Nacos) ServerContextPath() string {
+	cp := r.Spec.ContextPath
+	if cp == "" {
+		cp = DefaultContextPath
+	}
+	return strings.TrimSuffix(cp, "/")
+}
+
+// HealthCheckInterval 定期健康检查的周期，未设置或无法解析时使用operator的默认值
+func (r *Nacos) HealthCheckInterval(defaultInterval time.Duration) time.Duration {
+	if r.Spec.HealthCheckInterval == "" {
+		return defaultInterval
+	}
+	interval, err := time.ParseDuration(r.Spec.HealthCheckInterval)
+	if err != nil || interval < 0 {
+		return defaultInterval
+	}
+	return interval
+}
+
+// ReferencedSecrets 用户在spec中引用的secret名称，这些secret变化时需要重新reconcile
+func (r *Nacos) ReferencedSecrets() []string {
+	names := []string{}
+	for _, selector := range []*corev1.SecretKeySelector{
+		r.Spec.Database.UserSecretRef,
+		r.Spec.Database.PasswordSecretRef,
+		r.Spec.Auth.AdminPasswordSecretRef,
+		r.Spec.TLS.KeystorePasswordSecretRef,
+	} {
+		if selector != nil && selector.Name != "" {
+			names = append(names, selector.Name)
+		}
+	}
+	if ref := r.Spec.Auth.CredentialsSecretRef; ref != nil && ref.Name != "" {
+		names = append(names, ref.Name)
+	}
+	if r.Spec.TLS.SecretName != "" {
+		names = append(names, r.Spec.TLS.SecretName)
+	}
+	return names
+}
+
+// imageTag 去掉registry端口和digest后的tag
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
+
+func validateSecretKeySelector(path *field.Path, selector *corev1.SecretKeySelector) field.ErrorList {
+	var allErrs field.ErrorList
+	if selector == nil {
+		return allErrs
+	}
+	if selector.Name == "" {
+		allErrs = append(allErrs, field.Required(path.Child("name"), ""))
+	}
+	if selector.Key == "" {
+		allErrs = append(allErrs, field.Required(path.Child("key"), ""))
+	}
+	return allErrs
+}
+
+func (r *Nacos) toAggregate(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Nacos"}, r.Name, allErrs)
+}
+
+// ValidateProperties 检查配置是否为合法的properties格式
+func ValidateProperties(config string) error {
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	lineNum := 0
+	continued := false
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if !continued {
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+				continue
+			}
+			if strings.HasPrefix(line, "=") || strings.HasPrefix(line, ":") {
+				return fmt.Errorf("line %d: missing key", lineNum)
+			}
+		}
+		if err := validateEscapes(line); err != nil {
+			return fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		// 以奇数个反斜杠结尾表示续行
+		continued = (len(line)-len(strings.TrimRight(line, "\\")))%2 == 1
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if continued {
+		return fmt.Errorf("line %d: unterminated line continuation", lineNum)
+	}
+	return nil
+}
+
+// 检查\uXXXX转义是否合法
+func validateEscapes(line string) error {
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' {
+			continue
+		}
+		if i+1 < len(line) && line[i+1] == 'u' {
+			if i+6 > len(line) {
+				return fmt.Errorf("malformed \\uxxxx encoding")
+			}
+			for _, c := range line[i+2 : i+6] {
+				if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+					return fmt.Errorf("malformed \\uxxxx encoding")
+				}
+			}
+			i += 5
+			continue
+		}
+		// 跳过被转义的字符
+		i++
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func testNacos() *Nacos {
+	nacos := &Nacos{
+		ObjectMeta: metav1.ObjectMeta{Name: "nacos", Namespace: "default"},
+		Spec: NacosSpec{
+			Type:     TypeCluster,
+			Replicas: int32Ptr(3),
+			Image:    "nacos/nacos-server:1.4.1",
+		},
+	}
+	nacos.Default()
+	return nacos
+}
+
+func TestValidateSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(n *Nacos)
+		fields []string
+	}{
+		{"default", func(n *Nacos) {}, nil},
+		{"unknown type", func(n *Nacos) { n.Spec.Type = "ha" }, []string{"spec.type"}},
+		{"zero replicas", func(n *Nacos) { n.Spec.Replicas = int32Ptr(0) }, []string{"spec.replicas", "spec.replicas"}},
+		{"embedded cluster below 3", func(n *Nacos) { n.Spec.Replicas = int32Ptr(2) }, []string{"spec.replicas"}},
+		{"embedded standalone", func(n *Nacos) {
+			n.Spec.Type = TypeStandalone
+			n.Spec.Replicas = int32Ptr(1)
+		}, nil},
+		{"mysql without host", func(n *Nacos) { n.Spec.Database.TypeDatabase = DatabaseMysql }, []string{"spec.database.mysqlHost"}},
+		{"mysql secret ref without key", func(n *Nacos) {
+			n.Spec.Database.TypeDatabase = DatabaseMysql
+			n.Spec.Database.MysqlHost = "mysql"
+			n.Spec.Database.UserSecretRef = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "mysql"}}
+		}, []string{"spec.database.userSecretRef.key"}},
+		{"unknown database", func(n *Nacos) { n.Spec.Database.TypeDatabase = "pg" }, []string{"spec.database.type"}},
+		{"bad config", func(n *Nacos) { n.Spec.Config = "=value" }, []string{"spec.config"}},
+		{"snapshot without finalBackup", func(n *Nacos) { n.Spec.DeletionPolicy = DeletionPolicySnapshot }, []string{"spec.finalBackup"}},
+		{"unknown deletionPolicy", func(n *Nacos) { n.Spec.DeletionPolicy = "Orphan" }, []string{"spec.deletionPolicy"}},
+		{"monitoring interval", func(n *Nacos) { n.Spec.Monitoring.Interval = "30" }, []string{"spec.monitoring.interval"}},
+		{"negative healthCheckInterval", func(n *Nacos) { n.Spec.HealthCheckInterval = "-1m" }, []string{"spec.healthCheckInterval"}},
+		{"healthCheckInterval", func(n *Nacos) { n.Spec.HealthCheckInterval = "30s" }, nil},
+		{"contextPath without slash", func(n *Nacos) { n.Spec.ContextPath = "nacos" }, []string{"spec.contextPath"}},
+		{"root contextPath", func(n *Nacos) { n.Spec.ContextPath = "/" }, nil},
+		{"bad version", func(n *Nacos) { n.Spec.Version = "latest" }, []string{"spec.version"}},
+		{"tls without source", func(n *Nacos) { n.Spec.TLS.Enabled = true }, []string{"spec.tls"}},
+		{"tls secret without password", func(n *Nacos) {
+			n.Spec.TLS.Enabled = true
+			n.Spec.TLS.SecretName = "nacos-tls"
+		}, []string{"spec.tls.keystorePasswordSecretRef"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nacos := testNacos()
+			tt.mutate(nacos)
+			allErrs := nacos.ValidateSpec()
+			if len(allErrs) != len(tt.fields) {
+				t.Fatalf("errors = %v, want fields %v", allErrs, tt.fields)
+			}
+			for i, err := range allErrs {
+				if err.Field != tt.fields[i] {
+					t.Errorf("error %d field = %s, want %s", i, err.Field, tt.fields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateProperties(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{"empty", "", true},
+		{"comments", "# comment\n! comment\n\n", true},
+		{"key value", "nacos.core.auth.enabled=true\nserver.port: 8848", true},
+		{"key only", "nacos.standalone", true},
+		{"continuation", "a=1,\\\n  2", true},
+		{"escaped backslash at end", "a=c:\\\\", true},
+		{"unicode", "a=\\u4e2d", true},
+		{"missing key", "=value", false},
+		{"missing key with colon", ": value", false},
+		{"malformed unicode", "a=\\u4e2", false},
+		{"unicode with bad digit", "a=\\u4g2d", false},
+		{"unterminated continuation", "a=1\\", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProperties(tt.config)
+			if (err == nil) != tt.valid {
+				t.Errorf("ValidateProperties(%q) = %v, want valid %v", tt.config, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	invalid := testNacos()
+	invalid.Spec.Database.TypeDatabase = DatabaseMysql
+
+	tests := []struct {
+		name   string
+		old    *Nacos
+		mutate func(n *Nacos)
+		valid  bool
+	}{
+		{"scale down within quorum", testNacos(), func(n *Nacos) { n.Spec.Replicas = int32Ptr(3) }, true},
+		{"scale down below quorum one member at a time", func() *Nacos {
+			n := testNacos()
+			n.Spec.Replicas = int32Ptr(7)
+			return n
+		}(), func(n *Nacos) { n.Spec.Replicas = int32Ptr(3) }, true},
+		{"invalid spec changed", testNacos(), func(n *Nacos) { n.Spec.Database.TypeDatabase = DatabaseMysql }, false},
+		{"invalid spec unchanged", invalid, func(n *Nacos) {
+			n.Spec.Database.TypeDatabase = DatabaseMysql
+			n.Labels = map[string]string{"team": "a"}
+		}, true},
+		{"invalid spec deleting", testNacos(), func(n *Nacos) {
+			n.Spec.Database.TypeDatabase = DatabaseMysql
+			now := metav1.Now()
+			n.DeletionTimestamp = &now
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nacos := testNacos()
+			tt.mutate(nacos)
+			err := nacos.ValidateUpdate(tt.old)
+			if (err == nil) != tt.valid {
+				t.Errorf("ValidateUpdate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestReferencedSecrets(t *testing.T) {
+	nacos := testNacos()
+	if names := nacos.ReferencedSecrets(); len(names) != 0 {
+		t.Errorf("referenced secrets = %v, want none", names)
+	}
+	nacos.Spec.Database.PasswordSecretRef = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "mysql"}, Key: "password"}
+	nacos.Spec.Auth.AdminPasswordSecretRef = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "admin"}, Key: "password"}
+	nacos.Spec.Auth.CredentialsSecretRef = &corev1.LocalObjectReference{Name: "credentials"}
+	nacos.Spec.TLS.SecretName = "tls"
+	names := nacos.ReferencedSecrets()
+	want := []string{"mysql", "admin", "credentials", "tls"}
+	if len(names) != len(want) {
+		t.Fatalf("referenced secrets = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("referenced secrets = %v, want %v", names, want)
+		}
+	}
+}
+
+func TestMajorVersion(t *testing.T) {
+	tests := []struct {
+		image   string
+		version string
+		major   int
+	}{
+		{"nacos/nacos-server:1.4.1", "", 1},
+		{"nacos/nacos-server:v2.0.3", "", 2},
+		{"registry:5000/nacos/nacos-server:2.1.0-slim", "", 2},
+		{"registry:5000/nacos/nacos-server", "", 0},
+		{"nacos/nacos-server:2.0.3@sha256:abcd", "", 2},
+		{"nacos/nacos-server:latest", "", 0},
+		{"nacos/nacos-server:latest", "2.2.0", 2},
+	}
+	for _, tt := range tests {
+		nacos := testNacos()
+		nacos.Spec.Image = tt.image
+		nacos.Spec.Version = tt.version
+		if major := nacos.MajorVersion(); major != tt.major {
+			t.Errorf("MajorVersion(%s, %q) = %d, want %d", tt.image, tt.version, major, tt.major)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NacosBackupSpec defines the desired state of NacosBackup
+type NacosBackupSpec struct {
+	// 需要备份的Nacos实例，与备份在同一个namespace
+	NacosName string `json:"nacosName"`
+	// 备份文件的存储位置
+	Storage BackupStorage `json:"storage"`
+	// 每个配置导出的历史版本数，默认10，0表示不导出历史
+	// +optional
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
+	// 执行备份的镜像，默认使用operator自身的镜像
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// BackupStorage 备份存储，pvc和s3二选一
+type BackupStorage struct {
+	// 保存到pvc中
+	// +optional
+	PVC *PVCStorage `json:"pvc,omitempty"`
+	// 保存到兼容s3协议的对象存储中
+	// +optional
+	S3 *S3Storage `json:"s3,omitempty"`
+}
+
+type PVCStorage struct {
+	// pvc名称，需要与备份在同一个namespace
+	ClaimName string `json:"claimName"`
+	// 备份文件在pvc中的目录
+	// +optional
+	SubPath string `json:"subPath,omitempty"`
+}
+
+type S3Storage struct {
+	// 对象存储地址，例如 s3.amazonaws.com 或 minio.minio:9000
+	Endpoint string `json:"endpoint"`
+	Bucket   string `json:"bucket"`
+	// 对象名前缀
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// 默认us-east-1
+	// +optional
+	Region string `json:"region,omitempty"`
+	// 保存访问凭证的secret，key为accessKey和secretKey
+	CredentialsSecret string `json:"credentialsSecret"`
+	// 使用http访问
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+type BackupPhase string
+
+const (
+	BackupPhaseNone      BackupPhase = ""
+	BackupPhaseRunning   BackupPhase = "Running"
+	BackupPhaseSucceeded BackupPhase = "Succeeded"
+	BackupPhaseFailed    BackupPhase = "Failed"
+)
+
+// NacosBackupStatus defines the observed state of NacosBackup
+type NacosBackupStatus struct {
+	Phase BackupPhase `json:"phase,omitempty"`
+	// 备份文件的位置，例如 pvc://backup/default/nacos/nacos-backup.tar.gz
+	Location string `json:"location,omitempty"`
+	// 备份文件在存储中的路径，恢复时使用
+	Path string `json:"path,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// 失败原因
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// NacosBackup is the Schema for the nacosbackups API
+// +kubebuilder:printcolumn:name="Nacos",type=string,JSONPath=`.spec.nacosName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.status.location`
+// +kubebuilder:printcolumn:name="CreateTime",type=string,JSONPath=`.metadata.creationTimestamp`
+type NacosBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosBackupSpec   `json:"spec,omitempty"`
+	Status NacosBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosBackupList contains a list of NacosBackup
+type NacosBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosBackup{}, &NacosBackupList{})
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NacosBackupScheduleSpec defines the desired state of NacosBackupSchedule
+type NacosBackupScheduleSpec struct {
+	// cron表达式，例如 "0 2 * * *"，也支持 @daily、@every 6h 等写法，时区为UTC
+	Schedule string `json:"schedule"`
+	// 暂停调度，已经创建的备份不受影响
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// 创建的NacosBackup的spec
+	Template NacosBackupSpec `json:"template"`
+	// 保留策略，未配置时保留全部备份
+	// +optional
+	Retention *BackupRetention `json:"retention,omitempty"`
+}
+
+// BackupRetention 保留策略，满足任意一条规则的成功备份都会保留，其余的成功备份连同备份文件一起删除
+type BackupRetention struct {
+	// 保留最近的N个备份
+	// +optional
+	KeepLast *int32 `json:"keepLast,omitempty"`
+	// 保留最近N天每天最新的一个备份
+	// +optional
+	KeepDaily *int32 `json:"keepDaily,omitempty"`
+	// 保留最近N周每周最新的一个备份
+	// +optional
+	KeepWeekly *int32 `json:"keepWeekly,omitempty"`
+	// 保留的失败备份个数，默认3
+	// +optional
+	KeepFailed *int32 `json:"keepFailed,omitempty"`
+}
+
+// NacosBackupScheduleStatus defines the observed state of NacosBackupSchedule
+type NacosBackupScheduleStatus struct {
+	// 最近一次创建备份的时间
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// 最近一次成功备份的完成时间
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// 最近一次创建的备份
+	LastBackup string `json:"lastBackup,omitempty"`
+	// cron表达式错误等原因
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// NacosBackupSchedule is the Schema for the nacosbackupschedules API
+// +kubebuilder:printcolumn:name="Nacos",type=string,JSONPath=`.spec.template.nacosName`
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="LastSchedule",type=string,JSONPath=`.status.lastScheduleTime`
+// +kubebuilder:printcolumn:name="LastSuccessful",type=string,JSONPath=`.status.lastSuccessfulTime`
+type NacosBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosBackupScheduleSpec   `json:"spec,omitempty"`
+	Status NacosBackupScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosBackupScheduleList contains a list of NacosBackupSchedule
+type NacosBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosBackupSchedule{}, &NacosBackupScheduleList{})
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NacosConfigSpec defines the desired state of NacosConfig
+type NacosConfigSpec struct {
+	// 所属的Nacos实例，与cr在同一个namespace
+	NacosName string `json:"nacosName"`
+	// 命名空间id，为空时是public命名空间
+	// +optional
+	NamespaceId string `json:"namespaceId,omitempty"`
+	// 默认为DEFAULT_GROUP
+	// +optional
+	Group  string `json:"group,omitempty"`
+	DataId string `json:"dataId"`
+	// 配置格式，默认为text
+	// +kubebuilder:validation:Enum=text;json;xml;yaml;html;properties
+	// +optional
+	Type string `json:"type,omitempty"`
+	// 配置内容，和configMapRef只能设置一个
+	// +optional
+	Content string `json:"content,omitempty"`
+	// 从configmap读取配置内容，configmap变化后重新发布
+	// +optional
+	ConfigMapRef *v1.ConfigMapKeySelector `json:"configMapRef,omitempty"`
+	// +optional
+	AppName string `json:"appName,omitempty"`
+	// 灰度发布的客户端ip，设置后只发布beta配置，清空后发布正式配置并停止beta
+	// +optional
+	BetaIps []string `json:"betaIps,omitempty"`
+}
+
+// NacosConfigStatus defines the observed state of NacosConfig
+type NacosConfigStatus struct {
+	NacosResourceStatus `json:",inline"`
+	// 已经发布的配置，namespaceId、group或dataId修改后用于删除原来的配置
+	NamespaceId string `json:"namespaceId,omitempty"`
+	Group       string `json:"group,omitempty"`
+	DataId      string `json:"dataId,omitempty"`
+	// nacos中正式配置的md5
+	Md5 string `json:"md5,omitempty"`
+	// nacos中beta配置的md5，没有灰度发布时为空
+	BetaMd5 string `json:"betaMd5,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// NacosConfig is the Schema for the nacosconfigs API
+// +kubebuilder:printcolumn:name="Nacos",type=string,JSONPath=`.spec.nacosName`
+// +kubebuilder:printcolumn:name="DataId",type=string,JSONPath=`.spec.dataId`
+// +kubebuilder:printcolumn:name="Group",type=string,JSONPath=`.spec.group`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Md5",type=string,JSONPath=`.status.md5`
+// +kubebuilder:printcolumn:name="CreateTime",type=string,JSONPath=`.metadata.creationTimestamp`
+type NacosConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosConfigSpec   `json:"spec,omitempty"`
+	Status NacosConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosConfigList contains a list of NacosConfig
+type NacosConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosConfig{}, &NacosConfigList{})
+}
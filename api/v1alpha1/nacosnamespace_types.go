@@ -0,0 +1,93 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NacosNamespaceSpec defines the desired state of NacosNamespace
+type NacosNamespaceSpec struct {
+	// 所属的Nacos实例，与cr在同一个namespace
+	NacosName string `json:"nacosName"`
+	// 命名空间id，默认为cr名称，修改后删除原来的命名空间
+	// +optional
+	NamespaceId string `json:"namespaceId,omitempty"`
+	// 显示名称，默认为命名空间id
+	// +optional
+	ShowName string `json:"showName,omitempty"`
+	// +optional
+	Description string `json:"description,omitempty"`
+}
+
+type ResourcePhase string
+
+const (
+	ResourcePhaseNone   ResourcePhase = ""
+	ResourcePhaseSynced ResourcePhase = "Synced"
+	ResourcePhaseFailed ResourcePhase = "Failed"
+	// 已经删除，等待清理nacos中的数据
+	ResourcePhaseDeleting ResourcePhase = "Deleting"
+)
+
+// NacosResourceStatus NacosNamespace、NacosUser、NacosRole、NacosPermission共用的同步状态
+type NacosResourceStatus struct {
+	Phase ResourcePhase `json:"phase,omitempty"`
+	// 同步失败的原因
+	Message string `json:"message,omitempty"`
+	// 最近一次同步成功的时间
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// 最近一次同步成功的generation
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// NacosNamespaceStatus defines the observed state of NacosNamespace
+type NacosNamespaceStatus struct {
+	NacosResourceStatus `json:",inline"`
+	// 已经创建的命名空间id，spec.namespaceId修改后用于删除原来的命名空间
+	NamespaceId string `json:"namespaceId,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// NacosNamespace is the Schema for the nacosnamespaces API
+// +kubebuilder:printcolumn:name="Nacos",type=string,JSONPath=`.spec.nacosName`
+// +kubebuilder:printcolumn:name="NamespaceId",type=string,JSONPath=`.spec.namespaceId`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="CreateTime",type=string,JSONPath=`.metadata.creationTimestamp`
+type NacosNamespace struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosNamespaceSpec   `json:"spec,omitempty"`
+	Status NacosNamespaceStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosNamespaceList contains a list of NacosNamespace
+type NacosNamespaceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosNamespace `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosNamespace{}, &NacosNamespaceList{})
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NacosPermissionSpec defines the desired state of NacosPermission
+type NacosPermissionSpec struct {
+	// 所属的Nacos实例，与cr在同一个namespace
+	NacosName string `json:"nacosName"`
+	// 授权的角色，修改后回收原来角色的权限
+	Role string `json:"role"`
+	// 授予角色的权限
+	Permissions []Permission `json:"permissions"`
+}
+
+type Permission struct {
+	// 资源，格式为 <namespaceId>:*:*，public命名空间为 :*:*
+	Resource string `json:"resource"`
+	// r只读，w只写，rw读写
+	// +kubebuilder:validation:Enum=r;w;rw
+	Action string `json:"action"`
+}
+
+// NacosPermissionStatus defines the observed state of NacosPermission
+type NacosPermissionStatus struct {
+	NacosResourceStatus `json:",inline"`
+	// 已经授权的角色，spec.role修改后用于回收原来角色的权限
+	Role string `json:"role,omitempty"`
+	// 已经授予的权限，从spec.permissions中移除的权限会被回收
+	Permissions []Permission `json:"permissions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// NacosPermission is the Schema for the nacospermissions API
+// +kubebuilder:printcolumn:name="Nacos",type=string,JSONPath=`.spec.nacosName`
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.role`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="CreateTime",type=string,JSONPath=`.metadata.creationTimestamp`
+type NacosPermission struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosPermissionSpec   `json:"spec,omitempty"`
+	Status NacosPermissionStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosPermissionList contains a list of NacosPermission
+type NacosPermissionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosPermission `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosPermission{}, &NacosPermissionList{})
+}
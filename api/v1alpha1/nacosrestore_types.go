@@ -0,0 +1,89 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 同名配置已存在时的处理策略
+const (
+	RestorePolicyOverwrite = "overwrite"
+	RestorePolicySkip      = "skip"
+)
+
+// NacosRestoreSpec defines the desired state of NacosRestore
+type NacosRestoreSpec struct {
+	// 恢复到的Nacos实例，与恢复任务在同一个namespace
+	NacosName string `json:"nacosName"`
+	// 从已完成的NacosBackup恢复
+	// +optional
+	BackupName string `json:"backupName,omitempty"`
+	// 未指定backupName时，直接从存储中的备份文件恢复
+	// +optional
+	Storage *BackupStorage `json:"storage,omitempty"`
+	// 备份文件在存储中的路径，配合storage使用
+	// +optional
+	Path string `json:"path,omitempty"`
+	// 同名配置已存在时的处理策略，overwrite或skip，默认overwrite
+	// +optional
+	Policy string `json:"policy,omitempty"`
+	// 执行恢复的镜像，默认使用operator自身的镜像
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// NacosRestoreStatus defines the observed state of NacosRestore
+type NacosRestoreStatus struct {
+	Phase BackupPhase `json:"phase,omitempty"`
+	// 使用的备份文件位置
+	Location string `json:"location,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// 失败原因
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// NacosRestore is the Schema for the nacosrestores API
+// +kubebuilder:printcolumn:name="Nacos",type=string,JSONPath=`.spec.nacosName`
+// +kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.spec.backupName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="CreateTime",type=string,JSONPath=`.metadata.creationTimestamp`
+type NacosRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosRestoreSpec   `json:"spec,omitempty"`
+	Status NacosRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosRestoreList contains a list of NacosRestore
+type NacosRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosRestore{}, &NacosRestoreList{})
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NacosRoleSpec defines the desired state of NacosRole
+type NacosRoleSpec struct {
+	// 所属的Nacos实例，与cr在同一个namespace
+	NacosName string `json:"nacosName"`
+	// 角色名，默认为cr名称，修改后解绑原来的角色
+	// +optional
+	Role string `json:"role,omitempty"`
+	// 绑定该角色的用户，nacos中角色至少需要绑定一个用户才存在
+	Users []string `json:"users"`
+}
+
+// NacosRoleStatus defines the observed state of NacosRole
+type NacosRoleStatus struct {
+	NacosResourceStatus `json:",inline"`
+	// 已经绑定的角色名称，spec.role修改后用于解绑原来的角色
+	Role string `json:"role,omitempty"`
+	// 已经绑定的用户，从spec.users中移除的用户会被解绑
+	Users []string `json:"users,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// NacosRole is the Schema for the nacosroles API
+// +kubebuilder:printcolumn:name="Nacos",type=string,JSONPath=`.spec.nacosName`
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.role`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="CreateTime",type=string,JSONPath=`.metadata.creationTimestamp`
+type NacosRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosRoleSpec   `json:"spec,omitempty"`
+	Status NacosRoleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosRoleList contains a list of NacosRole
+type NacosRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosRole{}, &NacosRoleList{})
+}
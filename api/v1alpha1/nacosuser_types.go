@@ -0,0 +1,69 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NacosUserSpec defines the desired state of NacosUser
+type NacosUserSpec struct {
+	// 所属的Nacos实例，与cr在同一个namespace
+	NacosName string `json:"nacosName"`
+	// 用户名，默认为cr名称，修改后删除原来的用户。不能为管理员nacos
+	// +optional
+	Username string `json:"username,omitempty"`
+	// 密码所在的secret，secret内容变化或者在控制台被修改后会重新设置密码
+	PasswordSecretRef v1.SecretKeySelector `json:"passwordSecretRef"`
+}
+
+// NacosUserStatus defines the observed state of NacosUser
+type NacosUserStatus struct {
+	NacosResourceStatus `json:",inline"`
+	// 已经创建的用户名，spec.username修改后用于删除原来的用户
+	Username string `json:"username,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// NacosUser is the Schema for the nacosusers API
+// +kubebuilder:printcolumn:name="Nacos",type=string,JSONPath=`.spec.nacosName`
+// +kubebuilder:printcolumn:name="Username",type=string,JSONPath=`.spec.username`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="CreateTime",type=string,JSONPath=`.metadata.creationTimestamp`
+type NacosUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosUserSpec   `json:"spec,omitempty"`
+	Status NacosUserStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosUserList contains a list of NacosUser
+type NacosUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosUser{}, &NacosUserList{})
+}
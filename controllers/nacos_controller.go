@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"time"
 
@@ -25,15 +26,25 @@ import (
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
 
 	myErrors "nacos.io/nacos-operator/pkg/errors"
+	"nacos.io/nacos-operator/pkg/metrics"
+	"nacos.io/nacos-operator/pkg/service/k8s"
 )
 
 // NacosReconciler reconciles a Nacos object
@@ -42,11 +53,26 @@ type NacosReconciler struct {
 	Log            logr.Logger
 	Scheme         *runtime.Scheme
 	OperaterClient *operator.OperatorClient
+	// 成功后重新入队的周期，定期检查nacos的raft leader和节点状态，0表示不定期检查
+	HealthCheckInterval time.Duration
 }
 
 // +kubebuilder:rbac:groups=nacos.io,resources=nacos,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=nacos.io,resources=nacos/status,verbs=get;update;patch
-type reconcileFun func(nacos *nacosgroupv1alpha1.Nacos)
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps;services;pods;secrets;events;persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors;prometheusrules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+
+// reconcileFun 返回大于0的requeueAfter时终止后续步骤并在指定时间后重新入队，返回error时交由controller-runtime退避重试
+type reconcileFun func(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error)
+
+// reconcileStep 带名称的步骤，名称作为指标的step label
+type reconcileStep struct {
+	name string
+	fun  reconcileFun
+}
 
 func (r *NacosReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	_ = context.Background()
@@ -56,52 +82,61 @@ func (r *NacosReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	err := r.Client.Get(context.TODO(), req.NamespacedName, instance)
 	if err != nil {
 		if k8sErrors.IsNotFound(err) {
+			metrics.DeleteNacos(req.Namespace, req.Name)
 			return reconcile.Result{}, nil
 		}
 		return reconcile.Result{}, err
 	}
 
-	// 工作逻辑入口 , 引发了painc，返回默认false，重新插入队列,5秒继续执行
-	result := r.ReconcileWork(instance)
-	if result == false {
-		return reconcile.Result{
-			Requeue:      !result,
-			RequeueAfter: time.Second * 15}, nil
-	} else {
-		return reconcile.Result{}, nil
+	// 删除时按照deletionPolicy处理数据
+	if instance.DeletionTimestamp != nil {
+		requeueAfter, err := r.OperaterClient.MakeTeardown(instance)
+		if err != nil {
+			r.Log.Error(err, "teardown error", "namespace", instance.Namespace, "name", instance.Name)
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
 	}
 
+	// 工作逻辑入口
+	result, err := r.ReconcileWork(instance)
+	metrics.SetNacos(instance)
+	return result, err
 }
 
-func (r *NacosReconciler) ReconcileWork(instance *nacosgroupv1alpha1.Nacos) bool {
-	// 处理全局异常处理中的异常
-	defer func() {
-		if err := recover(); err != nil {
-			r.Log.Error(err.(error), "unknow error")
-		}
-	}()
-
-	// 全局处理异常
-	defer func() {
-		if err := recover(); err != nil {
-			// 可处理的异常
-			r.globalExceptHandle(err, instance)
-		}
-	}()
-
-	for _, fun := range []reconcileFun{
-		r.OperaterClient.PreCheck,
+func (r *NacosReconciler) ReconcileWork(instance *nacosgroupv1alpha1.Nacos) (ctrl.Result, error) {
+	for _, step := range []reconcileStep{
+		{"EnsureFinalizer", r.OperaterClient.EnsureFinalizer},
+		{"PreCheck", r.OperaterClient.PreCheck},
 		// 保证资源能够创建
-		r.OperaterClient.MakeEnsure,
+		{"MakeEnsure", r.OperaterClient.MakeEnsure},
+		// 设置管理员密码，之后的步骤都需要登录nacos
+		{"MakeAuth", r.OperaterClient.MakeAuth},
+		// 滚动更新，未完成时跳过检查
+		{"MakeRolling", r.OperaterClient.MakeRolling},
+		// 扩缩容，每次调整一个成员，未完成时跳过检查
+		{"MakeScale", r.OperaterClient.MakeScale},
 		// 检查并保障
-		r.OperaterClient.CheckAndMakeHeal,
+		{"CheckAndMakeHeal", r.OperaterClient.CheckAndMakeHeal},
 		// 保存状态
-		r.OperaterClient.UpdateStatus,
+		{"UpdateStatus", r.OperaterClient.UpdateStatus},
 	} {
-		fun(instance)
+		start := time.Now()
+		requeueAfter, err := step.fun(instance)
+		if err != nil {
+			metrics.ObserveReconcileStep(instance.Namespace, instance.Name, step.name, metrics.RESULT_ERROR, start)
+			r.handleError(err, instance)
+			return reconcile.Result{}, err
+		}
+		if requeueAfter > 0 {
+			metrics.ObserveReconcileStep(instance.Namespace, instance.Name, step.name, metrics.RESULT_REQUEUE, start)
+			return reconcile.Result{RequeueAfter: requeueAfter}, nil
+		}
+		metrics.ObserveReconcileStep(instance.Namespace, instance.Name, step.name, metrics.RESULT_SUCCESS, start)
 	}
 
-	return true
+	// nacos自身异常时没有k8s事件，定期重新检查
+	return reconcile.Result{RequeueAfter: instance.HealthCheckInterval(r.HealthCheckInterval)}, nil
 }
 
 func filterByLabel(label map[string]string) bool {
@@ -113,31 +148,110 @@ func filterByLabel(label map[string]string) bool {
 	}
 }
 
+// requestsByLabel pod和pvc由statefulset创建，没有指向cr的ownerReference，按label找到所属的nacos
+func requestsByLabel(a handler.MapObject) []reconcile.Request {
+	labels := a.Meta.GetLabels()
+	// 备份和恢复的job也带有middleware label，只关心nacos本身的pod
+	if !filterByLabel(labels) || labels["component"] != operator.NACOS || labels["app"] == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: a.Meta.GetNamespace(), Name: labels["app"]}}}
+}
+
+// resourceChanged 忽略只有status变化的更新，避免自身写status和kubelet上报状态引起大量reconcile
+// statefulset、pod和job中reconcile依赖的状态字段单独比较
+func resourceChanged(e event.UpdateEvent) bool {
+	if e.MetaOld == nil || e.MetaNew == nil {
+		return true
+	}
+	if e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+		!reflect.DeepEqual(e.MetaOld.GetDeletionTimestamp(), e.MetaNew.GetDeletionTimestamp()) ||
+		!reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels()) ||
+		!reflect.DeepEqual(e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations()) ||
+		!reflect.DeepEqual(e.MetaOld.GetOwnerReferences(), e.MetaNew.GetOwnerReferences()) {
+		return true
+	}
+	switch old := e.ObjectOld.(type) {
+	case *appsv1.StatefulSet:
+		new := e.ObjectNew.(*appsv1.StatefulSet)
+		return old.Status.ObservedGeneration != new.Status.ObservedGeneration ||
+			old.Status.Replicas != new.Status.Replicas ||
+			old.Status.ReadyReplicas != new.Status.ReadyReplicas ||
+			old.Status.CurrentRevision != new.Status.CurrentRevision ||
+			old.Status.UpdateRevision != new.Status.UpdateRevision
+	case *corev1.Pod:
+		new := e.ObjectNew.(*corev1.Pod)
+		return old.Status.Phase != new.Status.Phase ||
+			old.Status.PodIP != new.Status.PodIP ||
+			k8s.IsPodReady(old) != k8s.IsPodReady(new)
+	case *batchv1.Job:
+		new := e.ObjectNew.(*batchv1.Job)
+		return old.Status.Succeeded != new.Status.Succeeded || old.Status.Failed != new.Status.Failed
+	case *corev1.Service:
+		return !reflect.DeepEqual(old.Spec, e.ObjectNew.(*corev1.Service).Spec)
+	case *corev1.ConfigMap:
+		new := e.ObjectNew.(*corev1.ConfigMap)
+		return !reflect.DeepEqual(old.Data, new.Data) || !reflect.DeepEqual(old.BinaryData, new.BinaryData)
+	case *corev1.Secret:
+		return !reflect.DeepEqual(old.Data, e.ObjectNew.(*corev1.Secret).Data)
+	case *corev1.PersistentVolumeClaim:
+		new := e.ObjectNew.(*corev1.PersistentVolumeClaim)
+		return old.Status.Phase != new.Status.Phase || !reflect.DeepEqual(old.Spec, new.Spec)
+	}
+	return false
+}
+
+// requestsBySecret 用户引用的secret不属于nacos，按spec中的引用找到使用该secret的nacos
+func (r *NacosReconciler) requestsBySecret(a handler.MapObject) []reconcile.Request {
+	list := &nacosgroupv1alpha1.NacosList{}
+	if err := r.Client.List(context.TODO(), list, client.InNamespace(a.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "list nacos failed", "namespace", a.Meta.GetNamespace())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, nacos := range list.Items {
+		for _, name := range nacos.ReferencedSecrets() {
+			if name == a.Meta.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nacos.Namespace, Name: nacos.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
 func (r *NacosReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	changed := builder.WithPredicates(predicate.Funcs{UpdateFunc: resourceChanged})
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&nacosgroupv1alpha1.Nacos{}).
-		Owns(&appsv1.StatefulSet{}).
+		For(&nacosgroupv1alpha1.Nacos{}, changed).
+		Owns(&appsv1.StatefulSet{}, changed).
+		Owns(&corev1.Service{}, changed).
+		Owns(&corev1.ConfigMap{}, changed).
+		Owns(&corev1.Secret{}, changed).
+		Owns(&batchv1.Job{}, changed).
+		// pod和pvc没有指向cr的ownerReference
+		Watches(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(requestsByLabel)}, changed).
+		Watches(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(requestsByLabel)}, changed).
+		// 用户引用的mysql账号、管理员密码、访问账号和证书
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.requestsBySecret)}, changed).
 		Complete(r)
 }
 
-// 全局异常处理
-func (r *NacosReconciler) globalExceptHandle(err interface{}, instance *nacosgroupv1alpha1.Nacos) {
-	if reflect.TypeOf(err) == reflect.TypeOf(myErrors.NewErrMsg("")) {
-		myerr := err.(*myErrors.Err)
-		r.Log.V(0).Info("painc", "code", myerr.Code, "msg", myerr.Msg)
-		switch myerr.Code {
-		case myErrors.CODE_NORMAL:
-			r.OperaterClient.StatusClient.UpdateStatus(instance)
-			return
-		}
+// 异常处理，带错误码的异常记录到status中
+func (r *NacosReconciler) handleError(err error, instance *nacosgroupv1alpha1.Nacos) {
+	var myerr *myErrors.Err
+	if !errors.As(err, &myerr) {
+		// 未知的错误，由controller-runtime记录并重试
+		metrics.IncError(instance.Namespace, instance.Name, myErrors.CODE_ERR_UNKNOW)
+		r.Log.Error(err, "reconcile error", "namespace", instance.Namespace, "name", instance.Name)
+		return
+	}
+	r.Log.V(0).Info("reconcile failed", "code", myerr.Code, "msg", myerr.Msg)
+	metrics.IncError(instance.Namespace, instance.Name, myerr.Code)
 
-		// 超时3分钟如果还未成功就显示异常
-		if instance.Status.Phase != nacosgroupv1alpha1.PhaseCreating ||
-			instance.CreationTimestamp.Add(time.Minute*3).Before(time.Now()) {
-			r.OperaterClient.StatusClient.UpdateExceptionStatus(instance, myerr)
-		}
-	} else {
-		// 未知的错误，把堆栈打印出来
-		r.Log.Error(err.(error), "unknow error")
+	// 超时3分钟如果还未成功就显示异常
+	if instance.Status.Phase != nacosgroupv1alpha1.PhaseCreating ||
+		instance.CreationTimestamp.Add(time.Minute*3).Before(time.Now()) {
+		r.OperaterClient.StatusClient.UpdateExceptionStatus(instance, myerr)
 	}
 }
@@ -0,0 +1,66 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"nacos.io/nacos-operator/pkg/service/operator"
+)
+
+// NacosBackupReconciler reconciles a NacosBackup object
+type NacosBackupReconciler struct {
+	client.Client
+	Log            logr.Logger
+	Scheme         *runtime.Scheme
+	OperaterClient *operator.OperatorClient
+}
+
+// +kubebuilder:rbac:groups=nacos.io,resources=nacosbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=nacos.io,resources=nacosbackups/status,verbs=get;update;patch
+
+func (r *NacosBackupReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	instance := &nacosgroupv1alpha1.NacosBackup{}
+	if err := r.Client.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	requeueAfter, err := r.OperaterClient.MakeBackup(instance)
+	if err != nil {
+		r.Log.Error(err, "reconcile error", "namespace", instance.Namespace, "name", instance.Name)
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+func (r *NacosBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nacosgroupv1alpha1.NacosBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"nacos.io/nacos-operator/pkg/service/operator"
+)
+
+// NacosBackupScheduleReconciler reconciles a NacosBackupSchedule object
+type NacosBackupScheduleReconciler struct {
+	client.Client
+	Log            logr.Logger
+	Scheme         *runtime.Scheme
+	OperaterClient *operator.OperatorClient
+}
+
+// +kubebuilder:rbac:groups=nacos.io,resources=nacosbackupschedules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=nacos.io,resources=nacosbackupschedules/status,verbs=get;update;patch
+
+func (r *NacosBackupScheduleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	instance := &nacosgroupv1alpha1.NacosBackupSchedule{}
+	if err := r.Client.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	requeueAfter, err := r.OperaterClient.MakeBackupSchedule(instance)
+	if err != nil {
+		r.Log.Error(err, "reconcile error", "namespace", instance.Namespace, "name", instance.Name)
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+func (r *NacosBackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nacosgroupv1alpha1.NacosBackupSchedule{}).
+		// 定时备份创建的备份没有ownerReference，通过label找到所属的定时备份
+		Watches(&source.Kind{Type: &nacosgroupv1alpha1.NacosBackup{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+				name, ok := a.Meta.GetLabels()[operator.LABEL_BACKUP_SCHEDULE]
+				if !ok {
+					return nil
+				}
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: a.Meta.GetNamespace(), Name: name}}}
+			}),
+		}).
+		Complete(r)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"nacos.io/nacos-operator/pkg/service/operator"
+)
+
+// NacosConfigReconciler reconciles a NacosConfig object
+type NacosConfigReconciler struct {
+	client.Client
+	Log            logr.Logger
+	Scheme         *runtime.Scheme
+	OperaterClient *operator.OperatorClient
+}
+
+// +kubebuilder:rbac:groups=nacos.io,resources=nacosconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=nacos.io,resources=nacosconfigs/status,verbs=get;update;patch
+
+func (r *NacosConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	instance := &nacosgroupv1alpha1.NacosConfig{}
+	if err := r.Client.Get(context.TODO(), req.NamespacedName, instance); err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	requeueAfter, err := r.OperaterClient.MakeConfig(instance)
+	if err != nil {
+		r.Log.Error(err, "reconcile error", "namespace", instance.Namespace, "name", instance.Name)
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+func (r *NacosConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nacosgroupv1alpha1.NacosConfig{}).
+		// configmap变化后立即发布引用它的配置
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+				configs := &nacosgroupv1alpha1.NacosConfigList{}
+				if err := r.Client.List(context.TODO(), configs, client.InNamespace(a.Meta.GetNamespace())); err != nil {
+					r.Log.Error(err, "list nacos configs error", "namespace", a.Meta.GetNamespace())
+					return nil
+				}
+				requests := []reconcile.Request{}
+				for _, config := range configs.Items {
+					if config.Spec.ConfigMapRef != nil && config.Spec.ConfigMapRef.Name == a.Meta.GetName() {
+						requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: config.Namespace, Name: config.Name}})
+					}
+				}
+				return requests
+			}),
+		}).
+		Complete(r)
+}
@@ -19,7 +19,10 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
+	"nacos.io/nacos-operator/pkg/backup"
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
 	"nacos.io/nacos-operator/pkg/service/operator"
 
 	"k8s.io/client-go/kubernetes"
@@ -52,16 +55,27 @@ func init() {
 }
 
 func main() {
+	// 备份/恢复任务复用operator镜像，以子命令方式运行
+	if len(os.Args) > 1 && (os.Args[1] == backup.COMMAND_BACKUP || os.Args[1] == backup.COMMAND_RESTORE ||
+		os.Args[1] == backup.COMMAND_DELETE) {
+		os.Exit(backup.Main(os.Args[1]))
+	}
 
 	go func() {
 		http.ListenAndServe("0.0.0.0:8090", nil)
 	}()
 	var metricsAddr string
 	var enableLeaderElection bool
+	var nacosTimeout time.Duration
+	var healthCheckInterval time.Duration
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&nacosTimeout, "nacos-timeout", nacosClient.DEFAULT_TIMEOUT,
+		"Timeout of every request the operator sends to nacos.")
+	flag.DurationVar(&healthCheckInterval, "health-check-interval", time.Minute,
+		"Interval of the periodic health check of healthy clusters, overridden by spec.healthCheckInterval. 0 disables it.")
 	flag.Parse()
 
 	//ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -79,15 +93,96 @@ func main() {
 	}
 	log := ctrl.Log.WithName("controllers").WithName("Nacos")
 	clientset, _ := kubernetes.NewForConfig(ctrl.GetConfigOrDie())
+	operatorClient := operator.NewOperatorClient(log, clientset, mgr.GetScheme(), mgr.GetClient(), nacosTimeout, mgr.GetEventRecorderFor("nacos-operator"))
 	if err = (&controllers.NacosReconciler{
+		Client:              mgr.GetClient(),
+		Log:                 log,
+		Scheme:              mgr.GetScheme(),
+		OperaterClient:      operatorClient,
+		HealthCheckInterval: healthCheckInterval,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Nacos")
+		os.Exit(1)
+	}
+	if err = (&controllers.NacosBackupReconciler{
 		Client:         mgr.GetClient(),
-		Log:            log,
+		Log:            ctrl.Log.WithName("controllers").WithName("NacosBackup"),
 		Scheme:         mgr.GetScheme(),
-		OperaterClient: operator.NewOperatorClient(log, clientset, mgr.GetScheme(), mgr.GetClient()),
+		OperaterClient: operatorClient,
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Nacos")
+		setupLog.Error(err, "unable to create controller", "controller", "NacosBackup")
+		os.Exit(1)
+	}
+	if err = (&controllers.NacosRestoreReconciler{
+		Client:         mgr.GetClient(),
+		Log:            ctrl.Log.WithName("controllers").WithName("NacosRestore"),
+		Scheme:         mgr.GetScheme(),
+		OperaterClient: operatorClient,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NacosRestore")
+		os.Exit(1)
+	}
+	if err = (&controllers.NacosBackupScheduleReconciler{
+		Client:         mgr.GetClient(),
+		Log:            ctrl.Log.WithName("controllers").WithName("NacosBackupSchedule"),
+		Scheme:         mgr.GetScheme(),
+		OperaterClient: operatorClient,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NacosBackupSchedule")
+		os.Exit(1)
+	}
+	if err = (&controllers.NacosNamespaceReconciler{
+		Client:         mgr.GetClient(),
+		Log:            ctrl.Log.WithName("controllers").WithName("NacosNamespace"),
+		Scheme:         mgr.GetScheme(),
+		OperaterClient: operatorClient,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NacosNamespace")
 		os.Exit(1)
 	}
+	if err = (&controllers.NacosUserReconciler{
+		Client:         mgr.GetClient(),
+		Log:            ctrl.Log.WithName("controllers").WithName("NacosUser"),
+		Scheme:         mgr.GetScheme(),
+		OperaterClient: operatorClient,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NacosUser")
+		os.Exit(1)
+	}
+	if err = (&controllers.NacosRoleReconciler{
+		Client:         mgr.GetClient(),
+		Log:            ctrl.Log.WithName("controllers").WithName("NacosRole"),
+		Scheme:         mgr.GetScheme(),
+		OperaterClient: operatorClient,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NacosRole")
+		os.Exit(1)
+	}
+	if err = (&controllers.NacosPermissionReconciler{
+		Client:         mgr.GetClient(),
+		Log:            ctrl.Log.WithName("controllers").WithName("NacosPermission"),
+		Scheme:         mgr.GetScheme(),
+		OperaterClient: operatorClient,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NacosPermission")
+		os.Exit(1)
+	}
+	if err = (&controllers.NacosConfigReconciler{
+		Client:         mgr.GetClient(),
+		Log:            ctrl.Log.WithName("controllers").WithName("NacosConfig"),
+		Scheme:         mgr.GetScheme(),
+		OperaterClient: operatorClient,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NacosConfig")
+		os.Exit(1)
+	}
+	// webhook依赖证书，需要显式开启
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&nacosgroupv1alpha1.Nacos{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Nacos")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
+)
+
+// 备份文件格式版本，格式变化时需要兼容旧版本
+const ARCHIVE_VERSION = "v1"
+
+const (
+	manifestFile   = "manifest.json"
+	namespacesFile = "namespaces.json"
+	configsFile    = "configs.json"
+	historyFile    = "history.json"
+)
+
+// Manifest 备份文件的元信息
+type Manifest struct {
+	Version    string    `json:"version"`
+	Nacos      string    `json:"nacos"`
+	Namespace  string    `json:"namespace"`
+	CreateTime time.Time `json:"createTime"`
+	Configs    int       `json:"configs"`
+	History    int       `json:"history"`
+}
+
+// Archive 备份内容，以tar.gz格式保存，每部分为一个json文件，方便在集群外查看和迁移
+type Archive struct {
+	Manifest   Manifest
+	Namespaces []nacosClient.Namespace
+	Configs    []nacosClient.ConfigItem
+	History    []nacosClient.HistoryItem
+}
+
+func (a *Archive) Write(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	a.Manifest.Version = ARCHIVE_VERSION
+	a.Manifest.Configs = len(a.Configs)
+	a.Manifest.History = len(a.History)
+	for _, file := range []struct {
+		name string
+		data interface{}
+	}{
+		{manifestFile, a.Manifest},
+		{namespacesFile, a.Namespaces},
+		{configsFile, a.Configs},
+		{historyFile, a.History},
+	} {
+		data, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    file.name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: a.Manifest.CreateTime,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func ReadArchive(r io.Reader) (*Archive, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	a := &Archive{}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		var out interface{}
+		switch header.Name {
+		case manifestFile:
+			out = &a.Manifest
+		case namespacesFile:
+			out = &a.Namespaces
+		case configsFile:
+			out = &a.Configs
+		case historyFile:
+			out = &a.History
+		default:
+			continue
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("%s: %v", header.Name, err)
+		}
+	}
+	if a.Manifest.Version != ARCHIVE_VERSION {
+		return nil, fmt.Errorf("unsupported archive version %q", a.Manifest.Version)
+	}
+	return a, nil
+}
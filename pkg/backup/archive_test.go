@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+	"time"
+
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	archive := &Archive{
+		Manifest: Manifest{Nacos: "nacos", Namespace: "default", CreateTime: time.Date(2021, 3, 14, 9, 40, 12, 0, time.UTC)},
+		Namespaces: []nacosClient.Namespace{
+			{Namespace: "dev", NamespaceShowName: "dev", Type: 2},
+		},
+		Configs: []nacosClient.ConfigItem{
+			{DataId: "application.yaml", Group: "DEFAULT_GROUP", Tenant: "dev", Content: "a: 1\n", Type: "yaml"},
+			{DataId: "中文.properties", Group: "DEFAULT_GROUP", Content: "a=\\u4e2d"},
+		},
+		History: []nacosClient.HistoryItem{
+			{Id: "1", DataId: "application.yaml", Group: "DEFAULT_GROUP", Tenant: "dev", Content: "a: 0\n", OpType: "U"},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := archive.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	if archive.Manifest.Version != ARCHIVE_VERSION || archive.Manifest.Configs != 2 || archive.Manifest.History != 1 {
+		t.Errorf("manifest = %+v", archive.Manifest)
+	}
+
+	read, err := ReadArchive(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.Manifest.CreateTime.Equal(archive.Manifest.CreateTime) {
+		t.Errorf("createTime = %v, want %v", read.Manifest.CreateTime, archive.Manifest.CreateTime)
+	}
+	read.Manifest.CreateTime = archive.Manifest.CreateTime
+	if !reflect.DeepEqual(read, archive) {
+		t.Errorf("read archive = %+v, want %+v", read, archive)
+	}
+}
+
+func TestReadArchiveVersion(t *testing.T) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	data := []byte(`{"version":"v0"}`)
+	if err := tw.WriteHeader(&tar.Header{Name: manifestFile, Mode: 0644, Size: int64(len(data))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gw.Close()
+
+	if _, err := ReadArchive(buf); err == nil {
+		t.Errorf("ReadArchive() accepted version v0")
+	}
+	if _, err := ReadArchive(bytes.NewReader([]byte("not gzip"))); err == nil {
+		t.Errorf("ReadArchive() accepted a non gzip file")
+	}
+}
@@ -0,0 +1,295 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
+)
+
+// 备份/恢复任务的子命令，operator镜像以子命令方式运行在job中
+const (
+	COMMAND_BACKUP  = "backup"
+	COMMAND_RESTORE = "restore"
+	// 删除备份文件，保留策略清理备份时使用
+	COMMAND_DELETE = "delete"
+)
+
+// job中传递参数的环境变量
+const (
+	ENV_NACOS_ADDRESS   = "NACOS_ADDRESS"
+	ENV_NACOS_NAME      = "NACOS_NAME"
+	ENV_NACOS_NAMESPACE = "NACOS_NAMESPACE"
+	ENV_NACOS_USERNAME  = "NACOS_USERNAME"
+	ENV_NACOS_PASSWORD  = "NACOS_PASSWORD"
+	ENV_NACOS_CA        = "NACOS_CA"
+	// 为空时使用默认的8848和/nacos
+	ENV_NACOS_PORT         = "NACOS_PORT"
+	ENV_NACOS_CONTEXT_PATH = "NACOS_CONTEXT_PATH"
+	ENV_STORAGE            = "BACKUP_STORAGE"
+	ENV_DIR                = "BACKUP_DIR"
+	ENV_PATH               = "BACKUP_PATH"
+	ENV_HISTORY_LIMIT      = "BACKUP_HISTORY_LIMIT"
+	ENV_RESTORE_POLICY     = "RESTORE_POLICY"
+	ENV_S3_ENDPOINT        = "S3_ENDPOINT"
+	ENV_S3_BUCKET          = "S3_BUCKET"
+	ENV_S3_REGION          = "S3_REGION"
+	ENV_S3_ACCESS_KEY      = "S3_ACCESS_KEY"
+	ENV_S3_SECRET_KEY      = "S3_SECRET_KEY"
+	ENV_S3_INSECURE        = "S3_INSECURE"
+)
+
+const (
+	STORAGE_PVC = "pvc"
+	STORAGE_S3  = "s3"
+)
+
+// pvc在job中的挂载目录
+const DEFAULT_DIR = "/backup"
+
+// 分页获取配置的大小
+const PAGE_SIZE = 100
+
+type Options struct {
+	Address        string
+	NacosName      string
+	NacosNamespace string
+	Path           string
+	HistoryLimit   int
+	RestorePolicy  string
+	Storage        Storage
+	// 开启鉴权时访问nacos的账号
+	Username string
+	Password string
+	// 开启tls时校验服务端证书的ca，为空时使用http访问
+	CA string
+	// nacos的端口和访问路径
+	Port        int32
+	ContextPath string
+}
+
+// Main 子命令入口，返回进程退出码
+func Main(command string) int {
+	opts, err := optionsFromEnv(command)
+	if err != nil {
+		log.Printf("invalid options: %v", err)
+		return 1
+	}
+	// 使用默认超时，开启鉴权时缓存token
+	client := nacosClient.NewNacosClient(0)
+	if opts.Port != 0 {
+		client = client.WithEndpoint(opts.Port, opts.ContextPath)
+	}
+	if opts.CA != "" {
+		if client, err = client.WithTLS([]byte(opts.CA), opts.Address); err != nil {
+			log.Printf("invalid %s: %v", ENV_NACOS_CA, err)
+			return 1
+		}
+	}
+	if opts.Username != "" {
+		client = client.WithCredentials(&nacosClient.Credentials{Username: opts.Username, Password: opts.Password})
+	}
+	switch command {
+	case COMMAND_BACKUP:
+		err = Backup(client, opts)
+	case COMMAND_RESTORE:
+		err = Restore(client, opts)
+	case COMMAND_DELETE:
+		err = opts.Storage.Delete(opts.Path)
+	default:
+		err = fmt.Errorf("unknown command %s", command)
+	}
+	if err != nil {
+		log.Printf("%s failed: %v", command, err)
+		return 1
+	}
+	return 0
+}
+
+func optionsFromEnv(command string) (Options, error) {
+	opts := Options{
+		Address:        os.Getenv(ENV_NACOS_ADDRESS),
+		NacosName:      os.Getenv(ENV_NACOS_NAME),
+		NacosNamespace: os.Getenv(ENV_NACOS_NAMESPACE),
+		Username:       os.Getenv(ENV_NACOS_USERNAME),
+		Password:       os.Getenv(ENV_NACOS_PASSWORD),
+		CA:             os.Getenv(ENV_NACOS_CA),
+		Path:           os.Getenv(ENV_PATH),
+		RestorePolicy:  os.Getenv(ENV_RESTORE_POLICY),
+	}
+	if opts.Path == "" {
+		return opts, fmt.Errorf("%s is required", ENV_PATH)
+	}
+	// 删除备份文件不需要访问nacos
+	if opts.Address == "" && command != COMMAND_DELETE {
+		return opts, fmt.Errorf("%s is required", ENV_NACOS_ADDRESS)
+	}
+	if port := os.Getenv(ENV_NACOS_PORT); port != "" {
+		n, err := strconv.ParseInt(port, 10, 32)
+		if err != nil {
+			return opts, fmt.Errorf("%s: %v", ENV_NACOS_PORT, err)
+		}
+		opts.Port = int32(n)
+		opts.ContextPath = os.Getenv(ENV_NACOS_CONTEXT_PATH)
+	}
+	if limit := os.Getenv(ENV_HISTORY_LIMIT); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return opts, fmt.Errorf("%s: %v", ENV_HISTORY_LIMIT, err)
+		}
+		opts.HistoryLimit = n
+	}
+	storage, err := StorageFromEnv()
+	if err != nil {
+		return opts, err
+	}
+	opts.Storage = storage
+	return opts, nil
+}
+
+// StorageFromEnv 根据环境变量创建存储
+func StorageFromEnv() (Storage, error) {
+	switch os.Getenv(ENV_STORAGE) {
+	case STORAGE_PVC:
+		dir := os.Getenv(ENV_DIR)
+		if dir == "" {
+			dir = DEFAULT_DIR
+		}
+		return &FileStorage{Dir: dir}, nil
+	case STORAGE_S3:
+		return &S3Storage{
+			Endpoint:  os.Getenv(ENV_S3_ENDPOINT),
+			Bucket:    os.Getenv(ENV_S3_BUCKET),
+			Region:    os.Getenv(ENV_S3_REGION),
+			AccessKey: os.Getenv(ENV_S3_ACCESS_KEY),
+			SecretKey: os.Getenv(ENV_S3_SECRET_KEY),
+			Insecure:  os.Getenv(ENV_S3_INSECURE) == "true",
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q", os.Getenv(ENV_STORAGE))
+	}
+}
+
+// Backup 导出命名空间、配置和配置历史
+func Backup(client *nacosClient.NacosClient, opts Options) error {
+	archive := &Archive{
+		Manifest: Manifest{
+			Nacos:      opts.NacosName,
+			Namespace:  opts.NacosNamespace,
+			CreateTime: time.Now(),
+		},
+	}
+
+	namespaces, err := client.GetNamespaces(opts.Address)
+	if err != nil {
+		return fmt.Errorf("get namespaces: %v", err)
+	}
+	archive.Namespaces = namespaces
+
+	for _, ns := range namespaces {
+		for pageNo := 1; ; pageNo++ {
+			page, err := client.ListConfigs(opts.Address, ns.Namespace, pageNo, PAGE_SIZE)
+			if err != nil {
+				return fmt.Errorf("list configs of namespace %q: %v", ns.Namespace, err)
+			}
+			for _, config := range page.PageItems {
+				// 列表中的tenant可能为空，统一使用命名空间id
+				config.Tenant = ns.Namespace
+				archive.Configs = append(archive.Configs, config)
+				if opts.HistoryLimit <= 0 {
+					continue
+				}
+				history, err := exportHistory(client, opts, config)
+				if err != nil {
+					return err
+				}
+				archive.History = append(archive.History, history...)
+			}
+			if pageNo >= page.PagesAvailable {
+				break
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := archive.Write(buf); err != nil {
+		return err
+	}
+	if err := opts.Storage.Put(opts.Path, buf.Bytes()); err != nil {
+		return fmt.Errorf("save %s: %v", opts.Path, err)
+	}
+	log.Printf("backup %s: %d namespaces, %d configs, %d history", opts.Path, len(archive.Namespaces), len(archive.Configs), len(archive.History))
+	return nil
+}
+
+func exportHistory(client *nacosClient.NacosClient, opts Options, config nacosClient.ConfigItem) ([]nacosClient.HistoryItem, error) {
+	page, err := client.ListHistory(opts.Address, config.Tenant, config.Group, config.DataId, 1, opts.HistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("list history of %s/%s/%s: %v", config.Tenant, config.Group, config.DataId, err)
+	}
+	res := []nacosClient.HistoryItem{}
+	for _, item := range page.PageItems {
+		detail, err := client.GetHistory(opts.Address, string(item.Id))
+		if err != nil {
+			return nil, fmt.Errorf("get history %s: %v", item.Id, err)
+		}
+		detail.Tenant = config.Tenant
+		res = append(res, detail)
+	}
+	return res, nil
+}
+
+// Restore 重新创建缺失的命名空间并发布备份中的配置。
+// open api不支持写入配置历史，历史版本只保存在备份文件中
+func Restore(client *nacosClient.NacosClient, opts Options) error {
+	data, err := opts.Storage.Get(opts.Path)
+	if err != nil {
+		return fmt.Errorf("read %s: %v", opts.Path, err)
+	}
+	archive, err := ReadArchive(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("read archive %s: %v", opts.Path, err)
+	}
+
+	existing, err := client.GetNamespaces(opts.Address)
+	if err != nil {
+		return fmt.Errorf("get namespaces: %v", err)
+	}
+	exists := map[string]bool{}
+	for _, ns := range existing {
+		exists[ns.Namespace] = true
+	}
+	for _, ns := range archive.Namespaces {
+		if ns.Namespace == "" || exists[ns.Namespace] {
+			continue
+		}
+		if err := client.CreateNamespace(opts.Address, ns); err != nil {
+			return fmt.Errorf("create namespace %s: %v", ns.Namespace, err)
+		}
+	}
+
+	published, skipped := 0, 0
+	for _, config := range archive.Configs {
+		if opts.RestorePolicy == nacosgroupv1alpha1.RestorePolicySkip {
+			_, err := client.GetConfig(opts.Address, config.Tenant, config.Group, config.DataId)
+			if err == nil {
+				skipped++
+				continue
+			}
+			if !nacosClient.IsNotFound(err) {
+				return fmt.Errorf("get config %s/%s/%s: %v", config.Tenant, config.Group, config.DataId, err)
+			}
+		}
+		if err := client.PublishConfig(opts.Address, config); err != nil {
+			return fmt.Errorf("publish config %s/%s/%s: %v", config.Tenant, config.Group, config.DataId, err)
+		}
+		published++
+	}
+	log.Printf("restore %s: %d configs published, %d skipped", opts.Path, published, skipped)
+	return nil
+}
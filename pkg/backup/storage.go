@@ -0,0 +1,173 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage 备份文件的存储
+type Storage interface {
+	Put(path string, data []byte) error
+	Get(path string) ([]byte, error)
+	Delete(path string) error
+}
+
+// FileStorage 保存到挂载的pvc目录中
+type FileStorage struct {
+	Dir string
+}
+
+func (s *FileStorage) Put(path string, data []byte) error {
+	file := filepath.Join(s.Dir, path)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	// 先写临时文件，避免中断后留下不完整的备份
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func (s *FileStorage) Get(path string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(s.Dir, path))
+}
+
+func (s *FileStorage) Delete(path string) error {
+	err := os.Remove(filepath.Join(s.Dir, path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// S3Storage 兼容s3协议的对象存储，使用path-style地址和SigV4签名
+type S3Storage struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Insecure  bool
+
+	httpClient http.Client
+}
+
+func (s *S3Storage) Put(path string, data []byte) error {
+	_, err := s.do(http.MethodPut, path, data)
+	return err
+}
+
+func (s *S3Storage) Get(path string) ([]byte, error) {
+	return s.do(http.MethodGet, path, nil)
+}
+
+func (s *S3Storage) Delete(path string) error {
+	_, err := s.do(http.MethodDelete, path, nil)
+	return err
+}
+
+func (s *S3Storage) do(method string, path string, data []byte) ([]byte, error) {
+	scheme := "https"
+	if s.Insecure {
+		scheme = "http"
+	}
+	uri := "/" + s.Bucket + "/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequest(method, fmt.Sprintf("%s://%s%s", scheme, s.Endpoint, uriEncode(uri)), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, uri, data, time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("s3 %s %s: status %d: %s", method, uri, resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// sign 按照AWS Signature Version 4为请求签名
+func (s *S3Storage) sign(req *http.Request, uri string, payload []byte, now time.Time) {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.URL.Host, payloadHash, amzDate)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(uri),
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode 除了非保留字符和路径分隔符外全部编码
+func uriEncode(path string) string {
+	var buf strings.Builder
+	for _, b := range []byte(path) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || b == '/' {
+			buf.WriteByte(b)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,119 @@
+package backup
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	storage := &FileStorage{Dir: dir}
+	if err := storage.Put("default/nacos/backup.tar.gz", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := storage.Get("default/nacos/backup.tar.gz")
+	if err != nil || string(data) != "data" {
+		t.Fatalf("Get() = %q, %v", data, err)
+	}
+	if err := storage.Delete("default/nacos/backup.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete("default/nacos/backup.tar.gz"); err != nil {
+		t.Errorf("deleting a missing file: %v", err)
+	}
+}
+
+func TestUriEncode(t *testing.T) {
+	tests := map[string]string{
+		"/bucket/default/nacos-20210314.tar.gz": "/bucket/default/nacos-20210314.tar.gz",
+		"/bucket/a b+c":                         "/bucket/a%20b%2Bc",
+		"/bucket/中":                             "/bucket/%E4%B8%AD",
+		"/bucket/a~_.-":                         "/bucket/a~_.-",
+	}
+	for in, want := range tests {
+		if got := uriEncode(in); got != want {
+			t.Errorf("uriEncode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestS3Sign(t *testing.T) {
+	now := time.Date(2021, 3, 14, 9, 40, 12, 0, time.UTC)
+	sign := func(s *S3Storage, uri string, payload []byte) string {
+		req, _ := http.NewRequest(http.MethodPut, "https://minio:9000"+uriEncode(uri), nil)
+		s.sign(req, uri, payload, now)
+		return req.Header.Get("Authorization")
+	}
+	s := &S3Storage{AccessKey: "ak", SecretKey: "sk"}
+	auth := sign(s, "/bucket/backup.tar.gz", []byte("data"))
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=ak/20210314/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+		t.Errorf("authorization = %s", auth)
+	}
+	if auth != sign(s, "/bucket/backup.tar.gz", []byte("data")) {
+		t.Errorf("signature is not stable")
+	}
+	for name, other := range map[string]string{
+		"payload": sign(s, "/bucket/backup.tar.gz", []byte("other")),
+		"path":    sign(s, "/bucket/other.tar.gz", []byte("data")),
+		"secret":  sign(&S3Storage{AccessKey: "ak", SecretKey: "other"}, "/bucket/backup.tar.gz", []byte("data")),
+		"region":  sign(&S3Storage{AccessKey: "ak", SecretKey: "sk", Region: "cn-north-1"}, "/bucket/backup.tar.gz", []byte("data")),
+	} {
+		if other == auth {
+			t.Errorf("signature does not change with %s", name)
+		}
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	storage := &S3Storage{Endpoint: strings.TrimPrefix(server.URL, "http://"), Bucket: "bucket", AccessKey: "ak", SecretKey: "sk", Insecure: true}
+	if err := storage.Put("default/backup.tar.gz", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := objects["/bucket/default/backup.tar.gz"]; !ok {
+		t.Fatalf("objects = %v", objects)
+	}
+	data, err := storage.Get("/default/backup.tar.gz")
+	if err != nil || string(data) != "data" {
+		t.Fatalf("Get() = %q, %v", data, err)
+	}
+	if err := storage.Delete("default/backup.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Get("default/backup.tar.gz"); err == nil {
+		t.Errorf("Get() after Delete() succeeded")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 )
 
+// Err 带错误码的错误，reconcile流程中的每个步骤通过返回它来上报异常
 type Err struct {
 	Code int
 	Msg  string
@@ -15,34 +16,10 @@ func (e *Err) Error() string {
 	return string(err)
 }
 
-func EnsureNormal(err error) {
-	if err != nil {
-		panic(err)
-	}
-}
-
-func EnsureNormalMyError(err error, code int) {
-	if err != nil {
-		panic(New(code, err.Error()))
-	}
-}
-
-func EnsureEqual(i1 interface{}, i2 interface{}, code int, msg ...interface{}) {
-	if i1 != i2 {
-		panic(New(code, "msg: %v, %v is not equal %v", msg, i1, i2))
-	}
-}
-
-func EnsureNormalMsgf(err error, format string, a ...interface{}) {
-	if err != nil {
-		panic(err)
-	}
-}
-
 func New(code int, format string, a ...interface{}) *Err {
 	msg := fmt.Sprintf(format, a...)
 	if len(a) == 0 {
-		msg = fmt.Sprint(format, a)
+		msg = format
 	}
 	return &Err{
 		Code: code,
@@ -56,6 +33,15 @@ func NewErr(err error) *Err {
 		Msg:  err.Error(),
 	}
 }
+
+// NewErrWithCode 使用指定的错误码包装error
+func NewErrWithCode(err error, code int) *Err {
+	return &Err{
+		Code: code,
+		Msg:  err.Error(),
+	}
+}
+
 func NewErrMsg(err string) *Err {
 	return &Err{
 		Code: CODE_ERR_UNKNOW,
@@ -66,7 +52,7 @@ func NewErrMsg(err string) *Err {
 func NewErrfMsgf(format string, a ...interface{}) *Err {
 	msg := fmt.Sprintf(format, a...)
 	if len(a) == 0 {
-		msg = fmt.Sprint(format, a)
+		msg = format
 	}
 	return &Err{
 		Code: CODE_ERR_UNKNOW,
@@ -2,16 +2,54 @@ package errors
 
 // 2xx非错误
 const CODE_NORMAL = 200
+const CODE_SCALE = 201
+const CODE_BACKUP = 202
 
 // K8s资源层面错误 3XX
 const CODE_PARAMETER_ERROR = 301
 
 // 组件层面错误 4XXX
 const CODE_CLUSTER_FAILE = 401
+const CODE_POD_NOT_READY = 402
+const CODE_NODE_NOT_MATCH = 403
 const CODE_ERR_SYSTEM = 404
+const CODE_LEADER_SPLIT = 405
+const CODE_NODE_DOWN = 406
+const CODE_MYSQL_INIT_FAILED = 407
+const CODE_BACKUP_FAILED = 409
+const CODE_AUTH_FAILED = 410
+const CODE_TLS_NOT_READY = 411
+const CODE_ROLLING_TIMEOUT = 412
+
+// 自愈操作 5XX
+const CODE_HEAL = 501
 
 const CODE_ERR_UNKNOW = -1
 
+// 错误码对应的condition reason，保持稳定
+var reasons = map[int]string{
+	CODE_PARAMETER_ERROR:   "InvalidParameter",
+	CODE_CLUSTER_FAILE:     "ClusterUnreachable",
+	CODE_POD_NOT_READY:     "QuorumLost",
+	CODE_NODE_NOT_MATCH:    "MembersNotMatch",
+	CODE_ERR_SYSTEM:        "SystemError",
+	CODE_LEADER_SPLIT:      "LeaderSplit",
+	CODE_NODE_DOWN:         "MemberDown",
+	CODE_MYSQL_INIT_FAILED: "DatabaseInitFailed",
+	CODE_BACKUP_FAILED:     "BackupFailed",
+	CODE_AUTH_FAILED:       "AuthFailed",
+	CODE_TLS_NOT_READY:     "TLSNotReady",
+	CODE_ROLLING_TIMEOUT:   "RollingUpdateStuck",
+}
+
+// Reason 错误码对应的reason，未知的错误码返回Unknown
+func Reason(code int) string {
+	if reason, ok := reasons[code]; ok {
+		return reason
+	}
+	return "Unknown"
+}
+
 const MSG_PARAMETER_ERROT = "parameter error %v is %v"
 const MSG_NACOS_UNREACH = "nacos is nureach %s"
 const MSG_NACOS_CLUSTER = ""
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// 指标前缀
+const NAMESPACE = "nacos_operator"
+
+// reconcile步骤的结果
+const (
+	RESULT_SUCCESS = "success"
+	RESULT_REQUEUE = "requeue"
+	RESULT_ERROR   = "error"
+)
+
+// 导出为phase指标的状态，PhaseNone不导出
+var phases = []nacosgroupv1alpha1.Phase{
+	nacosgroupv1alpha1.PhaseCreating,
+	nacosgroupv1alpha1.PhaseRunning,
+	nacosgroupv1alpha1.PhaseFailed,
+	nacosgroupv1alpha1.PhaseScale,
+	nacosgroupv1alpha1.PhaseUpdating,
+}
+
+var (
+	// reconcile每个步骤的耗时和结果
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "reconcile_step_duration_seconds",
+		Help:      "Duration of each reconcile step of a Nacos, labelled by step and result (success, requeue, error).",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"namespace", "name", "step", "result"})
+
+	// 按错误码统计的错误次数
+	errorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "errors_total",
+		Help:      "Number of reconcile errors of a Nacos, labelled by error code.",
+	}, []string{"namespace", "name", "code"})
+
+	// 当前状态，当前phase为1，其余为0
+	phase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "phase",
+		Help:      "Current phase of a Nacos, 1 for the current phase and 0 for the others.",
+	}, []string{"namespace", "name", "phase"})
+
+	replicasDesired = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "replicas_desired",
+		Help:      "spec.replicas of a Nacos.",
+	}, []string{"namespace", "name"})
+
+	podsReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "pods_ready",
+		Help:      "Number of ready pods of a Nacos.",
+	}, []string{"namespace", "name"})
+
+	raftTerm = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "raft_term",
+		Help:      "Raft term of the naming_persistent_service group reported by a Nacos.",
+	}, []string{"namespace", "name"})
+
+	raftLeaderChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "raft_leader_changes_total",
+		Help:      "Number of raft leader changes observed by the operator.",
+	}, []string{"namespace", "name"})
+
+	// 每个节点的状态，当前state为1
+	nodeState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "node_state",
+		Help:      "State of each Nacos member (UP, DOWN, SUSPICIOUS ...) as reported by the servers api, 1 for the current state.",
+	}, []string{"namespace", "name", "node", "state"})
+
+	clusterLock sync.Mutex
+	// 记录上一次观察到的leader和节点状态，用于统计leader切换和清理过期的节点
+	lastLeader = map[string]string{}
+	lastNodes  = map[string]map[string]string{}
+
+	// 每个nacos最近一次成功备份的完成时间
+	backupLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "backup_last_success_timestamp_seconds",
+		Help:      "Completion time of the last successful backup of a Nacos, in unix seconds.",
+	}, []string{"namespace", "nacos"})
+
+	backupLock sync.Mutex
+	// gauge不能读取当前值，记录下来保证只会向后更新
+	backupLastSuccessTime = map[string]time.Time{}
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileDuration,
+		errorsTotal,
+		phase,
+		replicasDesired,
+		podsReady,
+		raftTerm,
+		raftLeaderChanges,
+		nodeState,
+		backupLastSuccess,
+	)
+}
+
+// ObserveReconcileStep 记录reconcile步骤的耗时和结果
+func ObserveReconcileStep(namespace string, name string, step string, result string, start time.Time) {
+	reconcileDuration.WithLabelValues(namespace, name, step, result).Observe(time.Since(start).Seconds())
+}
+
+// IncError 按错误码记录错误
+func IncError(namespace string, name string, code int) {
+	errorsTotal.WithLabelValues(namespace, name, strconv.Itoa(code)).Inc()
+}
+
+// SetNacos 记录nacos的状态和副本数
+func SetNacos(nacos *nacosgroupv1alpha1.Nacos) {
+	for _, p := range phases {
+		value := 0.0
+		if nacos.Status.Phase == p {
+			value = 1
+		}
+		phase.WithLabelValues(nacos.Namespace, nacos.Name, string(p)).Set(value)
+	}
+	if nacos.Spec.Replicas != nil {
+		replicasDesired.WithLabelValues(nacos.Namespace, nacos.Name).Set(float64(*nacos.Spec.Replicas))
+	}
+}
+
+// SetPodsReady 记录ready的pod数
+func SetPodsReady(namespace string, name string, ready int) {
+	podsReady.WithLabelValues(namespace, name).Set(float64(ready))
+}
+
+// ObserveRaft 记录raft的term和leader，leader与上一次观察到的不同时计为一次切换，并返回切换前的leader
+func ObserveRaft(namespace string, name string, leader string, term int) string {
+	clusterLock.Lock()
+	defer clusterLock.Unlock()
+	key := namespace + "/" + name
+	raftTerm.WithLabelValues(namespace, name).Set(float64(term))
+	if leader == "" {
+		return ""
+	}
+	last, ok := lastLeader[key]
+	lastLeader[key] = leader
+	if ok && last != leader {
+		raftLeaderChanges.WithLabelValues(namespace, name).Inc()
+		return last
+	}
+	return ""
+}
+
+// SetNodeStates 记录每个节点的状态，nodes为节点地址到状态的映射，已经不存在的节点会被清理
+func SetNodeStates(namespace string, name string, nodes map[string]string) {
+	clusterLock.Lock()
+	defer clusterLock.Unlock()
+	key := namespace + "/" + name
+	for node, state := range lastNodes[key] {
+		if nodes[node] != state {
+			nodeState.DeleteLabelValues(namespace, name, node, state)
+		}
+	}
+	for node, state := range nodes {
+		nodeState.WithLabelValues(namespace, name, node, state).Set(1)
+	}
+	lastNodes[key] = nodes
+}
+
+// DeleteNacos nacos删除后清理它的gauge
+func DeleteNacos(namespace string, name string) {
+	for _, p := range phases {
+		phase.DeleteLabelValues(namespace, name, string(p))
+	}
+	replicasDesired.DeleteLabelValues(namespace, name)
+	podsReady.DeleteLabelValues(namespace, name)
+	raftTerm.DeleteLabelValues(namespace, name)
+
+	clusterLock.Lock()
+	defer clusterLock.Unlock()
+	key := namespace + "/" + name
+	for node, state := range lastNodes[key] {
+		nodeState.DeleteLabelValues(namespace, name, node, state)
+	}
+	delete(lastNodes, key)
+	delete(lastLeader, key)
+}
+
+// SetBackupLastSuccess 记录成功备份的完成时间，operator重启后由备份的reconcile重新填充
+func SetBackupLastSuccess(namespace string, nacos string, t time.Time) {
+	backupLock.Lock()
+	defer backupLock.Unlock()
+	key := namespace + "/" + nacos
+	if last, ok := backupLastSuccessTime[key]; ok && !t.After(last) {
+		return
+	}
+	backupLastSuccessTime[key] = t
+	backupLastSuccess.WithLabelValues(namespace, nacos).Set(float64(t.Unix()))
+}
@@ -15,6 +15,7 @@ type Job interface {
 	GetJob(namespace string, name string) (*batchv1.Job, error)
 	CreateJob(namespace string, job *batchv1.Job) error
 	CreateIfNotExistsJob(namespace string, job *batchv1.Job) error
+	DeleteJob(namespace string, name string) error
 }
 
 type JobService struct {
@@ -57,3 +58,14 @@ func (s *JobService) CreateIfNotExistsJob(namespace string, job *batchv1.Job) er
 	}
 	return nil
 }
+
+func (s *JobService) DeleteJob(namespace string, name string) error {
+	// job删除后同时清理其创建的pod
+	propagation := metav1.DeletePropagationBackground
+	err := s.kubeClient.BatchV1().Jobs(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil {
+		return err
+	}
+	klog.V(2).Infof("delete job,namespace: %s  name: %s", namespace, name)
+	return nil
+}
@@ -11,6 +11,9 @@ type Services interface {
 	StatefulSet
 	Service
 	Job
+	Pod
+	Secret
+	PersistentVolumeClaim
 }
 
 type services struct {
@@ -18,14 +21,20 @@ type services struct {
 	StatefulSet
 	Service
 	Job
+	Pod
+	Secret
+	PersistentVolumeClaim
 }
 
 // New returns a new Kubernetes service.
 func NewK8sService(kubecli kubernetes.Interface, logger log.Logger) Services {
 	return &services{
-		ConfigMap:   NewConfigMapService(kubecli, logger),
-		StatefulSet: NewStatefulSetService(kubecli, logger),
-		Service:     NewServiceService(kubecli, logger),
-		Job:         NewJobService(kubecli, logger),
+		ConfigMap:             NewConfigMapService(kubecli, logger),
+		StatefulSet:           NewStatefulSetService(kubecli, logger),
+		Service:               NewServiceService(kubecli, logger),
+		Job:                   NewJobService(kubecli, logger),
+		Pod:                   NewPodService(kubecli, logger),
+		Secret:                NewSecretService(kubecli, logger),
+		PersistentVolumeClaim: NewPersistentVolumeClaimService(kubecli, logger),
 	}
 }
@@ -0,0 +1,96 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Pod the Pod service that knows how to interact with k8s to manage them
+type Pod interface {
+	GetPod(namespace string, name string) (*corev1.Pod, error)
+	DeletePod(namespace string, name string) error
+}
+
+// PodService is the pod service implementation using API calls to kubernetes.
+type PodService struct {
+	kubeClient kubernetes.Interface
+	logger     log.Logger
+}
+
+// NewPodService returns a new Pod KubeService.
+func NewPodService(kubeClient kubernetes.Interface, logger log.Logger) *PodService {
+	logger = logger.WithValues("service", "k8s.pod")
+	return &PodService{
+		kubeClient: kubeClient,
+		logger:     logger,
+	}
+}
+
+func (p *PodService) GetPod(namespace string, name string) (*corev1.Pod, error) {
+	pod, err := p.kubeClient.CoreV1().Pods(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return pod, err
+}
+
+func (p *PodService) DeletePod(namespace string, name string) error {
+	err := p.kubeClient.CoreV1().Pods(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil {
+		return err
+	}
+	p.logger.WithValues("namespace", namespace).WithValues("pod", name).Info("pod deleted")
+	return nil
+}
+
+// IsPodReady 按类型查找Ready condition，删除中的pod视为未ready
+func IsPodReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// PodNotReadyReason pod未ready的原因，例如调度失败、容器等待的原因和重启次数
+func PodNotReadyReason(pod *corev1.Pod) string {
+	if pod.DeletionTimestamp != nil {
+		return "terminating"
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+			return fmt.Sprintf("%s: %s", condition.Reason, condition.Message)
+		}
+	}
+	reasons := []string{}
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		switch {
+		case status.State.Waiting != nil:
+			reasons = append(reasons, fmt.Sprintf("%s is waiting: %s (restarts %d)", status.Name, status.State.Waiting.Reason, status.RestartCount))
+		case status.State.Terminated != nil && status.State.Terminated.ExitCode != 0:
+			reasons = append(reasons, fmt.Sprintf("%s terminated: %s (restarts %d)", status.Name, status.State.Terminated.Reason, status.RestartCount))
+		case status.State.Running != nil && !status.Ready:
+			reasons = append(reasons, fmt.Sprintf("%s is not ready (restarts %d)", status.Name, status.RestartCount))
+		}
+	}
+	if len(reasons) > 0 {
+		return strings.Join(reasons, ", ")
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Reason != "" {
+			return fmt.Sprintf("%s: %s", condition.Reason, condition.Message)
+		}
+	}
+	return fmt.Sprintf("phase is %s", pod.Status.Phase)
+}
@@ -0,0 +1,48 @@
+package k8s
+
+import (
+	"context"
+
+	log "github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PersistentVolumeClaim the PersistentVolumeClaim service that knows how to interact with k8s to manage them
+type PersistentVolumeClaim interface {
+	ListPersistentVolumeClaims(namespace string, selector map[string]string) (*corev1.PersistentVolumeClaimList, error)
+	DeletePersistentVolumeClaim(namespace string, name string) error
+}
+
+// PersistentVolumeClaimService is the pvc service implementation using API calls to kubernetes.
+type PersistentVolumeClaimService struct {
+	kubeClient kubernetes.Interface
+	logger     log.Logger
+}
+
+// NewPersistentVolumeClaimService returns a new PersistentVolumeClaim KubeService.
+func NewPersistentVolumeClaimService(kubeClient kubernetes.Interface, logger log.Logger) *PersistentVolumeClaimService {
+	logger = logger.WithValues("service", "k8s.pvc")
+	return &PersistentVolumeClaimService{
+		kubeClient: kubeClient,
+		logger:     logger,
+	}
+}
+
+func (p *PersistentVolumeClaimService) ListPersistentVolumeClaims(namespace string, selector map[string]string) (*corev1.PersistentVolumeClaimList, error) {
+	return p.kubeClient.CoreV1().PersistentVolumeClaims(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(selector).String(),
+	})
+}
+
+func (p *PersistentVolumeClaimService) DeletePersistentVolumeClaim(namespace string, name string) error {
+	err := p.kubeClient.CoreV1().PersistentVolumeClaims(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	p.logger.WithValues("namespace", namespace).WithValues("pvc", name).Info("pvc deleted")
+	return nil
+}
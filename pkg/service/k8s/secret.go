@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"context"
+
+	log "github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Secret the Secret service that knows how to interact with k8s to manage them
+type Secret interface {
+	GetSecret(namespace string, name string) (*corev1.Secret, error)
+	CreateSecret(namespace string, secret *corev1.Secret) error
+	UpdateSecret(namespace string, secret *corev1.Secret) error
+	CreateIfNotExistsSecret(namespace string, secret *corev1.Secret) error
+	DeleteSecret(namespace string, name string) error
+}
+
+// SecretService is the secret service implementation using API calls to kubernetes.
+type SecretService struct {
+	kubeClient kubernetes.Interface
+	logger     log.Logger
+}
+
+// NewSecretService returns a new Secret KubeService.
+func NewSecretService(kubeClient kubernetes.Interface, logger log.Logger) *SecretService {
+	logger = logger.WithValues("service", "k8s.secret")
+	return &SecretService{
+		kubeClient: kubeClient,
+		logger:     logger,
+	}
+}
+
+func (p *SecretService) GetSecret(namespace string, name string) (*corev1.Secret, error) {
+	secret, err := p.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return secret, err
+}
+
+func (p *SecretService) CreateSecret(namespace string, secret *corev1.Secret) error {
+	_, err := p.kubeClient.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	p.logger.WithValues("namespace", namespace).WithValues("secret", secret.Name).Info("secret created")
+	return nil
+}
+
+func (p *SecretService) UpdateSecret(namespace string, secret *corev1.Secret) error {
+	_, err := p.kubeClient.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	p.logger.WithValues("namespace", namespace).WithValues("secret", secret.Name).Info("secret updated")
+	return nil
+}
+
+// CreateIfNotExistsSecret 已存在的secret不会被覆盖，避免随机生成的密码被重置
+func (p *SecretService) CreateIfNotExistsSecret(namespace string, secret *corev1.Secret) error {
+	if _, err := p.GetSecret(namespace, secret.Name); err != nil {
+		// If no resource we need to create.
+		if errors.IsNotFound(err) {
+			return p.CreateSecret(namespace, secret)
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *SecretService) DeleteSecret(namespace string, name string) error {
+	return p.kubeClient.CoreV1().Secrets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+}
@@ -52,6 +52,7 @@ func (s *StatefulSetService) GetStatefulSet(namespace, name string) (*appsv1.Sta
 }
 
 // GetStatefulSetPods will give a list of pods that are managed by the statefulset
+// 只返回由statefulset controller创建并且属于这个statefulset的pod，忽略label相同的其他pod
 func (s *StatefulSetService) GetStatefulSetPods(namespace, name string) (*corev1.PodList, error) {
 	statefulSet, err := s.GetStatefulSet(namespace, name)
 	if err != nil {
@@ -62,23 +63,30 @@ func (s *StatefulSetService) GetStatefulSetPods(namespace, name string) (*corev1
 		labels = append(labels, fmt.Sprintf("%s=%s", k, v))
 	}
 	selector := strings.Join(labels, ",")
-	return s.kubeClient.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	podList, err := s.kubeClient.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	owned := []corev1.Pod{}
+	for _, pod := range podList.Items {
+		if !metav1.IsControlledBy(&pod, statefulSet) || pod.Labels[appsv1.ControllerRevisionHashLabelKey] == "" {
+			continue
+		}
+		owned = append(owned, pod)
+	}
+	podList.Items = owned
+	return podList, nil
 }
 
+// GetStatefulSetReadPod 返回ready的pod
 func (s *StatefulSetService) GetStatefulSetReadPod(namespace, name string) ([]corev1.Pod, error) {
 	var podlist []corev1.Pod
 	podList, err := s.GetStatefulSetPods(namespace, name)
 	if err != nil {
 		return podlist, err
 	}
-	num := 0
 	for _, pod := range podList.Items {
-		if len(pod.Status.Conditions) != 4 {
-			continue
-		}
-		if pod.Status.Conditions[1].Type == "Ready" &&
-			pod.Status.Conditions[1].Status == "True" {
-			num = num + 1
+		if IsPodReady(&pod) {
 			podlist = append(podlist, pod)
 		}
 	}
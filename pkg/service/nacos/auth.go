@@ -0,0 +1,248 @@
+package nacosClient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 分页获取用户、角色、权限时的大小
+const AUTH_PAGE_SIZE = 100
+
+// User nacos用户，password为bcrypt加密后的密码
+type User struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RoleBinding nacos中角色和用户的绑定关系，角色至少绑定一个用户才存在
+type RoleBinding struct {
+	Role     string `json:"role"`
+	Username string `json:"username"`
+}
+
+type Permission struct {
+	Role     string `json:"role"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// Credentials 开启鉴权后访问nacos的账号
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// LoginResult 登录返回的token，tokenTtl单位为秒
+type LoginResult struct {
+	AccessToken string `json:"accessToken"`
+	TokenTtl    int64  `json:"tokenTtl"`
+	GlobalAdmin bool   `json:"globalAdmin"`
+}
+
+type authPage struct {
+	TotalCount     int `json:"totalCount"`
+	PageNumber     int `json:"pageNumber"`
+	PagesAvailable int `json:"pagesAvailable"`
+}
+
+// listPages 依次获取每一页，直到最后一页
+func listPages(fetch func(pageNo int) (authPage, error)) error {
+	for pageNo := 1; ; pageNo++ {
+		page, err := fetch(pageNo)
+		if err != nil {
+			return err
+		}
+		if pageNo >= page.PagesAvailable {
+			return nil
+		}
+	}
+}
+
+func pageQuery(query url.Values, pageNo int) url.Values {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("pageNo", strconv.Itoa(pageNo))
+	q.Set("pageSize", strconv.Itoa(AUTH_PAGE_SIZE))
+	return q
+}
+
+// tokenCache 按地址和账号缓存token，在tokenTtl之前刷新
+type tokenCache struct {
+	lock   sync.Mutex
+	tokens map[string]cachedToken
+}
+
+type cachedToken struct {
+	accessToken string
+	expireAt    time.Time
+}
+
+func (t *tokenCache) get(key string) (string, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	token, ok := t.tokens[key]
+	if !ok || time.Now().After(token.expireAt) {
+		return "", false
+	}
+	return token.accessToken, true
+}
+
+func (t *tokenCache) set(key string, login LoginResult) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	// 提前10%刷新，避免使用时刚好过期
+	ttl := time.Duration(login.TokenTtl) * time.Second * 9 / 10
+	t.tokens[key] = cachedToken{accessToken: login.AccessToken, expireAt: time.Now().Add(ttl)}
+}
+
+// accessToken 优先使用缓存的token，refresh为true时重新登录
+func (c *NacosClient) accessToken(ip string, refresh bool) (string, error) {
+	// 密码变化后使用新的缓存
+	sum := sha256.Sum256([]byte(c.credentials.Password))
+	key := ip + "/" + c.credentials.Username + "/" + hex.EncodeToString(sum[:8])
+	if c.tokens != nil && !refresh {
+		if token, ok := c.tokens.get(key); ok {
+			return token, nil
+		}
+	}
+	login, err := c.Login(ip, c.credentials.Username, c.credentials.Password)
+	if err != nil {
+		return "", err
+	}
+	if c.tokens != nil {
+		c.tokens.set(key, login)
+	}
+	return login.AccessToken, nil
+}
+
+// WithCredentials 返回使用指定账号访问的client，credentials为空时匿名访问
+func (c *NacosClient) WithCredentials(credentials *Credentials) *NacosClient {
+	cli := c.clone()
+	cli.credentials = credentials
+	return cli
+}
+
+// Login 登录获取accessToken，账号或密码错误时返回403
+func (c *NacosClient) Login(ip string, username string, password string) (LoginResult, error) {
+	res := LoginResult{}
+	form := url.Values{}
+	form.Set("username", username)
+	form.Set("password", password)
+	anonymous := c.WithCredentials(nil)
+	err := anonymous.postForm(ip, "/v1/auth/login", form, &res)
+	return res, err
+}
+
+func (c *NacosClient) ListUsers(ip string) ([]User, error) {
+	users := []User{}
+	err := listPages(func(pageNo int) (authPage, error) {
+		page := struct {
+			authPage
+			PageItems []User `json:"pageItems"`
+		}{}
+		if err := c.get(ip, "/v1/auth/users", pageQuery(nil, pageNo), &page); err != nil {
+			return page.authPage, err
+		}
+		users = append(users, page.PageItems...)
+		return page.authPage, nil
+	})
+	return users, err
+}
+
+func (c *NacosClient) CreateUser(ip string, username string, password string) error {
+	form := url.Values{}
+	form.Set("username", username)
+	form.Set("password", password)
+	return c.postForm(ip, "/v1/auth/users", form, nil)
+}
+
+func (c *NacosClient) UpdateUserPassword(ip string, username string, password string) error {
+	form := url.Values{}
+	form.Set("username", username)
+	form.Set("newPassword", password)
+	return c.putForm(ip, "/v1/auth/users", form, nil)
+}
+
+func (c *NacosClient) DeleteUser(ip string, username string) error {
+	query := url.Values{}
+	query.Set("username", username)
+	return c.delete(ip, "/v1/auth/users", query, nil)
+}
+
+// ListRoles 获取角色绑定，username为空时返回全部
+func (c *NacosClient) ListRoles(ip string, username string) ([]RoleBinding, error) {
+	roles := []RoleBinding{}
+	query := url.Values{}
+	if username != "" {
+		query.Set("username", username)
+	}
+	err := listPages(func(pageNo int) (authPage, error) {
+		page := struct {
+			authPage
+			PageItems []RoleBinding `json:"pageItems"`
+		}{}
+		if err := c.get(ip, "/v1/auth/roles", pageQuery(query, pageNo), &page); err != nil {
+			return page.authPage, err
+		}
+		roles = append(roles, page.PageItems...)
+		return page.authPage, nil
+	})
+	return roles, err
+}
+
+func (c *NacosClient) CreateRole(ip string, role string, username string) error {
+	form := url.Values{}
+	form.Set("role", role)
+	form.Set("username", username)
+	return c.postForm(ip, "/v1/auth/roles", form, nil)
+}
+
+// DeleteRole 解除角色和用户的绑定，username为空时删除整个角色
+func (c *NacosClient) DeleteRole(ip string, role string, username string) error {
+	query := url.Values{}
+	query.Set("role", role)
+	if username != "" {
+		query.Set("username", username)
+	}
+	return c.delete(ip, "/v1/auth/roles", query, nil)
+}
+
+func (c *NacosClient) ListPermissions(ip string, role string) ([]Permission, error) {
+	permissions := []Permission{}
+	query := url.Values{}
+	query.Set("role", role)
+	err := listPages(func(pageNo int) (authPage, error) {
+		page := struct {
+			authPage
+			PageItems []Permission `json:"pageItems"`
+		}{}
+		if err := c.get(ip, "/v1/auth/permissions", pageQuery(query, pageNo), &page); err != nil {
+			return page.authPage, err
+		}
+		permissions = append(permissions, page.PageItems...)
+		return page.authPage, nil
+	})
+	return permissions, err
+}
+
+func (c *NacosClient) CreatePermission(ip string, permission Permission) error {
+	form := url.Values{}
+	form.Set("role", permission.Role)
+	form.Set("resource", permission.Resource)
+	form.Set("action", permission.Action)
+	return c.postForm(ip, "/v1/auth/permissions", form, nil)
+}
+
+func (c *NacosClient) DeletePermission(ip string, permission Permission) error {
+	query := url.Values{}
+	query.Set("role", permission.Role)
+	query.Set("resource", permission.Resource)
+	query.Set("action", permission.Action)
+	return c.delete(ip, "/v1/auth/permissions", query, nil)
+}
@@ -0,0 +1,97 @@
+package nacosClient
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeAuthServer 模拟nacos的登录接口，token按登录次数递增，只接受最新的token
+type fakeAuthServer struct {
+	lock   sync.Mutex
+	logins int
+	users  []string
+}
+
+func (s *fakeAuthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if r.URL.Path == "/nacos/v1/auth/login" {
+		if r.FormValue("password") != "nacos" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		s.logins++
+		fmt.Fprintf(w, `{"accessToken":"token-%d","tokenTtl":18000}`, s.logins)
+		return
+	}
+	if r.URL.Query().Get("accessToken") != fmt.Sprintf("token-%d", s.logins) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	s.users = append(s.users, r.FormValue("username"))
+}
+
+func (s *fakeAuthServer) expire() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// 模拟nacos重启后之前的token全部失效
+	s.logins++
+}
+
+func testClient(t *testing.T, server *httptest.Server, password string) (*NacosClient, string) {
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	cli := NewNacosClient(0).WithEndpoint(int32(p), DEFAULT_CONTEXT_PATH)
+	return cli.WithCredentials(&Credentials{Username: "nacos", Password: password}), host
+}
+
+func TestAccessTokenRefresh(t *testing.T) {
+	fake := &fakeAuthServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cli, ip := testClient(t, server, "nacos")
+
+	for i := 0; i < 2; i++ {
+		if err := cli.CreateUser(ip, fmt.Sprintf("user-%d", i), "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.logins != 1 {
+		t.Errorf("logins = %d, want the token to be cached", fake.logins)
+	}
+
+	fake.expire()
+	if err := cli.CreateUser(ip, "user-2", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.logins != 3 {
+		t.Errorf("logins = %d, want a new login after 403", fake.logins)
+	}
+	want := []string{"user-0", "user-1", "user-2"}
+	if fmt.Sprint(fake.users) != fmt.Sprint(want) {
+		t.Errorf("users = %v, want %v with the form body resent", fake.users, want)
+	}
+}
+
+func TestAccessTokenWrongPassword(t *testing.T) {
+	fake := &fakeAuthServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	cli, ip := testClient(t, server, "wrong")
+
+	err := cli.CreateUser(ip, "user-0", "password")
+	if !IsForbidden(err) {
+		t.Errorf("err = %v, want forbidden", err)
+	}
+	if len(fake.users) != 0 {
+		t.Errorf("users = %v, want none", fake.users)
+	}
+}
@@ -3,59 +3,136 @@ package nacosClient
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type INacosClient interface {
 }
 
+// 默认的端口和访问路径
+const DEFAULT_PORT = 8848
+const DEFAULT_CONTEXT_PATH = "/nacos"
+
+// 每个请求的默认超时时间，避免一个卡住的pod阻塞整个reconcile
+const DEFAULT_TIMEOUT = time.Second * 10
+
 type NacosClient struct {
 	logger     log.Logger
 	httpClient http.Client
+	// 开启鉴权后使用的账号，为空时匿名访问
+	credentials *Credentials
+	// 登录获取的token，为空时每次请求都重新登录
+	tokens *tokenCache
+	// 开启tls后为https
+	scheme string
+	// 端口和访问路径，例如:8848/nacos，为空时使用默认值
+	endpoint string
+	// 按ca和serverName复用transport，避免每次reconcile都新建连接
+	transports *transportCache
+}
+
+// NewNacosClient 创建带超时和token缓存的client，timeout为0时使用DEFAULT_TIMEOUT
+func NewNacosClient(timeout time.Duration) *NacosClient {
+	if timeout <= 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+	return &NacosClient{
+		httpClient: http.Client{Timeout: timeout},
+		tokens:     &tokenCache{tokens: map[string]cachedToken{}},
+		transports: &transportCache{transports: map[string]*http.Transport{}},
+	}
+}
+
+// clone 复制client的配置，token和transport的缓存共用
+func (c *NacosClient) clone() *NacosClient {
+	return &NacosClient{
+		httpClient:  c.httpClient,
+		credentials: c.credentials,
+		tokens:      c.tokens,
+		scheme:      c.scheme,
+		endpoint:    c.endpoint,
+		transports:  c.transports,
+	}
+}
+
+// WithEndpoint 返回访问指定端口和访问路径的client，contextPath为空表示根路径
+func (c *NacosClient) WithEndpoint(port int32, contextPath string) *NacosClient {
+	cli := c.clone()
+	cli.endpoint = fmt.Sprintf(":%d%s", port, strings.TrimSuffix(contextPath, "/"))
+	return cli
 }
 
 type ServersInfo struct {
-	Servers []struct {
-		IP         string `json:"ip"`
-		Port       int    `json:"port"`
-		State      string `json:"state"`
-		ExtendInfo struct {
-			LastRefreshTime int64 `json:"lastRefreshTime"`
-			RaftMetaData    struct {
-				MetaDataMap struct {
-					NamingPersistentService struct {
-						Leader          string   `json:"leader"`
-						RaftGroupMember []string `json:"raftGroupMember"`
-						Term            int      `json:"term"`
-					} `json:"naming_persistent_service"`
-				} `json:"metaDataMap"`
-			} `json:"raftMetaData"`
-			RaftPort string `json:"raftPort"`
-			Version  string `json:"version"`
-		} `json:"extendInfo"`
-		Address       string `json:"address"`
-		FailAccessCnt int    `json:"failAccessCnt"`
-	} `json:"servers"`
+	Servers []ServerNode `json:"servers"`
+}
+
+// ServerNode 集群成员，1.x和2.x的接口返回相同的结构
+type ServerNode struct {
+	IP         string `json:"ip"`
+	Port       int    `json:"port"`
+	State      string `json:"state"`
+	ExtendInfo struct {
+		LastRefreshTime int64 `json:"lastRefreshTime"`
+		RaftMetaData    struct {
+			// key为raft group的名称
+			MetaDataMap map[string]RaftGroup `json:"metaDataMap"`
+		} `json:"raftMetaData"`
+		RaftPort string `json:"raftPort"`
+		Version  string `json:"version"`
+	} `json:"extendInfo"`
+	Address       string `json:"address"`
+	FailAccessCnt int    `json:"failAccessCnt"`
+}
+
+// 1.x和2.x中naming模块使用的raft group，2.x中内置数据库的配置还会使用nacos_config
+const (
+	RAFT_GROUP_NAMING_PERSISTENT    = "naming_persistent_service"
+	RAFT_GROUP_NAMING_PERSISTENT_V2 = "naming_persistent_service_v2"
+	RAFT_GROUP_INSTANCE_METADATA    = "naming_instance_metadata"
+	RAFT_GROUP_SERVICE_METADATA     = "naming_service_metadata"
+)
+
+// RaftGroup 节点看到的raft group状态，leader为空表示还未选出leader
+type RaftGroup struct {
+	Leader          string   `json:"leader"`
+	RaftGroupMember []string `json:"raftGroupMember"`
+	Term            int      `json:"term"`
+}
+
+// v2接口统一的返回结构
+type v2Result struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
 }
 
 func (c *NacosClient) GetClusterNodes(ip string) (ServersInfo, error) {
 	servers := ServersInfo{}
-	resp, err := c.httpClient.Get(fmt.Sprintf("http://%s:8848/nacos/v1/ns/operator/servers", ip))
-	if err != nil {
-		return servers, err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return servers, err
+	if err := c.get(ip, "/v1/ns/operator/servers", nil, &servers); err != nil {
+		return servers, fmt.Errorf("instance: %s ; %s", ip, err.Error())
 	}
+	return servers, nil
+}
 
-	err = json.Unmarshal(body, &servers)
+// GetClusterNodesV2 2.x的集群接口，2.2之前没有v2接口，返回404时使用/v1/core/cluster/nodes
+func (c *NacosClient) GetClusterNodesV2(ip string) (ServersInfo, error) {
+	servers := ServersInfo{}
+	res := v2Result{}
+	err := c.get(ip, "/v2/core/cluster/node/list", nil, &res)
+	if IsNotFound(err) {
+		err = c.get(ip, "/v1/core/cluster/nodes", nil, &res)
+	}
 	if err != nil {
-		fmt.Printf("%s\n", body)
-		return servers, fmt.Errorf(fmt.Sprintf("instance: %s ; %s ;body: %v", ip, err.Error(), string(body)))
+		return servers, fmt.Errorf("instance: %s ; %s", ip, err.Error())
+	}
+	if res.Code != 0 && res.Code != 200 {
+		return servers, fmt.Errorf("instance: %s ; code: %d, message: %s", ip, res.Code, res.Message)
+	}
+	if err := json.Unmarshal(res.Data, &servers.Servers); err != nil {
+		return servers, fmt.Errorf("instance: %s ; %s", ip, err.Error())
 	}
 	return servers, nil
 }
@@ -0,0 +1,384 @@
+package nacosClient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Namespace nacos命名空间
+type Namespace struct {
+	Namespace         string `json:"namespace"`
+	NamespaceShowName string `json:"namespaceShowName"`
+	NamespaceDesc     string `json:"namespaceDesc,omitempty"`
+	Quota             int    `json:"quota"`
+	ConfigCount       int    `json:"configCount"`
+	// 0为public，2为自定义
+	Type int `json:"type"`
+}
+
+// ConfigItem nacos配置
+type ConfigItem struct {
+	DataId  string `json:"dataId"`
+	Group   string `json:"group"`
+	Content string `json:"content"`
+	Md5     string `json:"md5,omitempty"`
+	Tenant  string `json:"tenant"`
+	AppName string `json:"appName,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// BetaConfig 灰度发布的配置，betaIps为逗号分隔的客户端ip
+type BetaConfig struct {
+	ConfigItem
+	BetaIps string `json:"betaIps"`
+}
+
+type ConfigPage struct {
+	TotalCount     int          `json:"totalCount"`
+	PageNumber     int          `json:"pageNumber"`
+	PagesAvailable int          `json:"pagesAvailable"`
+	PageItems      []ConfigItem `json:"pageItems"`
+}
+
+// HistoryItem 配置的历史版本，列表接口不返回content，需要通过GetHistory获取
+type HistoryItem struct {
+	Id               FlexString `json:"id"`
+	DataId           string     `json:"dataId"`
+	Group            string     `json:"group"`
+	Tenant           string     `json:"tenant"`
+	AppName          string     `json:"appName,omitempty"`
+	Content          string     `json:"content,omitempty"`
+	Md5              string     `json:"md5,omitempty"`
+	SrcIp            string     `json:"srcIp,omitempty"`
+	SrcUser          string     `json:"srcUser,omitempty"`
+	OpType           string     `json:"opType,omitempty"`
+	CreatedTime      string     `json:"createdTime,omitempty"`
+	LastModifiedTime string     `json:"lastModifiedTime,omitempty"`
+}
+
+type HistoryPage struct {
+	TotalCount     int           `json:"totalCount"`
+	PageNumber     int           `json:"pageNumber"`
+	PagesAvailable int           `json:"pagesAvailable"`
+	PageItems      []HistoryItem `json:"pageItems"`
+}
+
+// FlexString 兼容数字和字符串两种格式的id
+type FlexString string
+
+func (f *FlexString) UnmarshalJSON(data []byte) error {
+	*f = FlexString(strings.Trim(string(data), "\""))
+	return nil
+}
+
+func (c *NacosClient) url(ip string, path string, query url.Values) string {
+	scheme := c.scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf(":%d%s", DEFAULT_PORT, DEFAULT_CONTEXT_PATH)
+	}
+	u := fmt.Sprintf("%s://%s%s%s", scheme, ip, endpoint, path)
+	if len(query) > 0 {
+		u = u + "?" + query.Encode()
+	}
+	return u
+}
+
+// 执行请求，非2xx返回错误，out不为空时解析json
+func (c *NacosClient) do(req *http.Request, out interface{}) error {
+	body, err := c.doRaw(req)
+	if err != nil {
+		return err
+	}
+	// 查询不到数据时部分接口返回空的body
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s: %s ;body: %v", req.URL.Path, err.Error(), string(body))
+	}
+	return nil
+}
+
+// doRaw 开启鉴权时带上accessToken，token失效返回403时重新登录后重试一次
+func (c *NacosClient) doRaw(req *http.Request) ([]byte, error) {
+	if c.credentials == nil {
+		return c.send(req)
+	}
+	token, err := c.accessToken(req.URL.Hostname(), false)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.send(withAccessToken(req, token))
+	if !IsForbidden(err) {
+		return body, err
+	}
+	// nacos重启或者密码变化后token失效
+	if token, err = c.accessToken(req.URL.Hostname(), true); err != nil {
+		return nil, err
+	}
+	if req.GetBody != nil {
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return c.send(withAccessToken(req, token))
+}
+
+func (c *NacosClient) send(req *http.Request) ([]byte, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &HttpError{StatusCode: resp.StatusCode, Body: string(body), Url: req.URL.Path}
+	}
+	return body, nil
+}
+
+func withAccessToken(req *http.Request, token string) *http.Request {
+	query := req.URL.Query()
+	query.Set("accessToken", token)
+	req.URL.RawQuery = query.Encode()
+	return req
+}
+
+func (c *NacosClient) get(ip string, path string, query url.Values, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.url(ip, path, query), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, out)
+}
+
+func (c *NacosClient) postForm(ip string, path string, form url.Values, out interface{}) error {
+	return c.sendForm(http.MethodPost, ip, path, form, out)
+}
+
+func (c *NacosClient) putForm(ip string, path string, form url.Values, out interface{}) error {
+	return c.sendForm(http.MethodPut, ip, path, form, out)
+}
+
+func (c *NacosClient) sendForm(method string, ip string, path string, form url.Values, out interface{}) error {
+	return c.sendFormWithHeader(method, ip, path, form, nil, out)
+}
+
+func (c *NacosClient) sendFormWithHeader(method string, ip string, path string, form url.Values, header http.Header, out interface{}) error {
+	req, err := http.NewRequest(method, c.url(ip, path, nil), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, out)
+}
+
+// delete nacos的删除接口通过query传参
+func (c *NacosClient) delete(ip string, path string, query url.Values, out interface{}) error {
+	req, err := http.NewRequest(http.MethodDelete, c.url(ip, path, query), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, out)
+}
+
+// HttpError nacos返回的非2xx响应
+type HttpError struct {
+	StatusCode int
+	Body       string
+	Url        string
+}
+
+func (e *HttpError) Error() string {
+	return fmt.Sprintf("%s: status %d: %s", e.Url, e.StatusCode, e.Body)
+}
+
+// IsForbidden 判断是否是403错误，未登录、账号密码错误或者没有权限
+func IsForbidden(err error) bool {
+	if e, ok := err.(*HttpError); ok {
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// IsNotFound 判断是否是404错误
+func IsNotFound(err error) bool {
+	if e, ok := err.(*HttpError); ok {
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+func (c *NacosClient) GetNamespaces(ip string) ([]Namespace, error) {
+	res := struct {
+		Code int         `json:"code"`
+		Data []Namespace `json:"data"`
+	}{}
+	if err := c.get(ip, "/v1/console/namespaces", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+func (c *NacosClient) CreateNamespace(ip string, namespace Namespace) error {
+	form := url.Values{}
+	form.Set("customNamespaceId", namespace.Namespace)
+	form.Set("namespaceName", namespace.NamespaceShowName)
+	form.Set("namespaceDesc", namespace.NamespaceDesc)
+	return c.postForm(ip, "/v1/console/namespaces", form, nil)
+}
+
+func (c *NacosClient) UpdateNamespace(ip string, namespace Namespace) error {
+	form := url.Values{}
+	form.Set("namespace", namespace.Namespace)
+	form.Set("namespaceShowName", namespace.NamespaceShowName)
+	form.Set("namespaceDesc", namespace.NamespaceDesc)
+	return c.putForm(ip, "/v1/console/namespaces", form, nil)
+}
+
+func (c *NacosClient) DeleteNamespace(ip string, namespaceId string) error {
+	query := url.Values{}
+	query.Set("namespaceId", namespaceId)
+	return c.delete(ip, "/v1/console/namespaces", query, nil)
+}
+
+// ListConfigs 分页获取命名空间下的全部配置
+func (c *NacosClient) ListConfigs(ip string, tenant string, pageNo int, pageSize int) (ConfigPage, error) {
+	page := ConfigPage{}
+	query := url.Values{}
+	query.Set("search", "accurate")
+	query.Set("dataId", "")
+	query.Set("group", "")
+	query.Set("tenant", tenant)
+	query.Set("pageNo", strconv.Itoa(pageNo))
+	query.Set("pageSize", strconv.Itoa(pageSize))
+	err := c.get(ip, "/v1/cs/configs", query, &page)
+	return page, err
+}
+
+// GetConfig 获取配置内容，配置不存在时返回404错误
+func (c *NacosClient) GetConfig(ip string, tenant string, group string, dataId string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, c.url(ip, "/v1/cs/configs", configQuery(tenant, group, dataId)), nil)
+	if err != nil {
+		return "", err
+	}
+	body, err := c.doRaw(req)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// GetConfigDetail 获取配置的详情，包含md5和type，配置不存在时返回nil
+func (c *NacosClient) GetConfigDetail(ip string, tenant string, group string, dataId string) (*ConfigItem, error) {
+	var item *ConfigItem
+	query := configQuery(tenant, group, dataId)
+	query.Set("show", "all")
+	if err := c.get(ip, "/v1/cs/configs", query, &item); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+func (c *NacosClient) PublishConfig(ip string, config ConfigItem) error {
+	return c.postForm(ip, "/v1/cs/configs", publishForm(config), nil)
+}
+
+func (c *NacosClient) DeleteConfig(ip string, tenant string, group string, dataId string) error {
+	return c.delete(ip, "/v1/cs/configs", configQuery(tenant, group, dataId), nil)
+}
+
+// GetBetaConfig 获取beta配置，没有灰度发布时返回nil
+func (c *NacosClient) GetBetaConfig(ip string, tenant string, group string, dataId string) (*BetaConfig, error) {
+	res := struct {
+		Code int         `json:"code"`
+		Data *BetaConfig `json:"data"`
+	}{}
+	query := configQuery(tenant, group, dataId)
+	query.Set("beta", "true")
+	if err := c.get(ip, "/v1/cs/configs", query, &res); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// PublishBetaConfig 灰度发布，只有betaIps中的客户端能获取到
+func (c *NacosClient) PublishBetaConfig(ip string, config ConfigItem, betaIps []string) error {
+	header := http.Header{}
+	header.Set("betaIps", strings.Join(betaIps, ","))
+	return c.sendFormWithHeader(http.MethodPost, ip, "/v1/cs/configs", publishForm(config), header, nil)
+}
+
+// StopBetaConfig 停止灰度发布，删除beta配置
+func (c *NacosClient) StopBetaConfig(ip string, tenant string, group string, dataId string) error {
+	query := configQuery(tenant, group, dataId)
+	query.Set("beta", "true")
+	return c.delete(ip, "/v1/cs/configs", query, nil)
+}
+
+func configQuery(tenant string, group string, dataId string) url.Values {
+	query := url.Values{}
+	query.Set("tenant", tenant)
+	query.Set("group", group)
+	query.Set("dataId", dataId)
+	return query
+}
+
+func publishForm(config ConfigItem) url.Values {
+	form := url.Values{}
+	form.Set("tenant", config.Tenant)
+	form.Set("group", config.Group)
+	form.Set("dataId", config.DataId)
+	form.Set("content", config.Content)
+	if config.Type != "" {
+		form.Set("type", config.Type)
+	}
+	if config.AppName != "" {
+		form.Set("appName", config.AppName)
+	}
+	return form
+}
+
+// ListHistory 分页获取配置的历史版本，按时间倒序
+func (c *NacosClient) ListHistory(ip string, tenant string, group string, dataId string, pageNo int, pageSize int) (HistoryPage, error) {
+	page := HistoryPage{}
+	query := url.Values{}
+	query.Set("search", "accurate")
+	query.Set("tenant", tenant)
+	query.Set("group", group)
+	query.Set("dataId", dataId)
+	query.Set("pageNo", strconv.Itoa(pageNo))
+	query.Set("pageSize", strconv.Itoa(pageSize))
+	err := c.get(ip, "/v1/cs/history", query, &page)
+	return page, err
+}
+
+// GetHistory 获取历史版本的详情
+func (c *NacosClient) GetHistory(ip string, nid string) (HistoryItem, error) {
+	item := HistoryItem{}
+	query := url.Values{}
+	query.Set("nid", nid)
+	err := c.get(ip, "/v1/cs/history", query, &item)
+	return item, err
+}
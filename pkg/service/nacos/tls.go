@@ -0,0 +1,57 @@
+package nacosClient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// transportCache 按ca和serverName缓存transport
+type transportCache struct {
+	lock       sync.Mutex
+	transports map[string]*http.Transport
+}
+
+func (t *transportCache) get(caPEM []byte, serverName string) (*http.Transport, error) {
+	sum := sha256.Sum256(caPEM)
+	key := hex.EncodeToString(sum[:]) + "/" + serverName
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if transport, ok := t.transports[key]; ok {
+		return transport, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no valid certificate found in ca")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	t.transports[key] = transport
+	return transport, nil
+}
+
+// WithTLS 返回使用https访问的client，使用caPEM校验服务端证书；
+// operator通过pod ip访问nacos，serverName用于校验证书中的域名
+func (c *NacosClient) WithTLS(caPEM []byte, serverName string) (*NacosClient, error) {
+	transports := c.transports
+	if transports == nil {
+		transports = &transportCache{transports: map[string]*http.Transport{}}
+	}
+	transport, err := transports.get(caPEM, serverName)
+	if err != nil {
+		return nil, err
+	}
+	cli := c.clone()
+	cli.httpClient = http.Client{Timeout: c.httpClient.Timeout, Transport: transport}
+	cli.scheme = "https"
+	cli.transports = transports
+	return cli, nil
+}
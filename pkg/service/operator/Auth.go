@@ -0,0 +1,109 @@
+package operator
+
+import (
+	"time"
+
+	log "github.com/go-logr/logr"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	myErrors "nacos.io/nacos-operator/pkg/errors"
+	"nacos.io/nacos-operator/pkg/service/k8s"
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
+)
+
+type IAuthClient interface {
+	MakeAuth(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error)
+}
+
+type AuthClient struct {
+	k8sService k8s.Services
+	logger     log.Logger
+	kindClient *KindClient
+}
+
+func NewAuthClient(logger log.Logger, k8sService k8s.Services, kindClient *KindClient) *AuthClient {
+	return &AuthClient{
+		k8sService: k8sService,
+		logger:     logger,
+		kindClient: kindClient,
+	}
+}
+
+// MakeAuth 保证管理员nacos的密码和期望的一致：首次使用初始密码登录后修改，
+// adminPasswordSecretRef变化后使用当前密码登录后修改，修改成功后记录到<name>-auth中并标记为已生效
+func (c *AuthClient) MakeAuth(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	if !nacos.Spec.Auth.Enabled {
+		return 0, nil
+	}
+	secret, err := c.k8sService.GetSecret(nacos.Namespace, c.kindClient.generateAuthSecretName(nacos))
+	if err != nil {
+		return 0, myErrors.NewErr(err)
+	}
+	current := string(secret.Data[AUTH_SECRET_ADMIN_PASSWORD])
+	desired, err := c.desiredPassword(nacos, current)
+	if err != nil {
+		return 0, err
+	}
+
+	ip := c.kindClient.generateAccessAddress(nacos)
+	anonymous, err := c.kindClient.baseNacosClient(nacos)
+	if err != nil {
+		return 0, err
+	}
+	// 上一次修改密码后保存secret失败时，nacos中已经是desired
+	used := ""
+	for _, password := range []string{current, desired, NACOS_DEFAULT_PASSWORD} {
+		_, err := anonymous.Login(ip, NACOS_ADMIN_USER, password)
+		if err == nil {
+			used = password
+			break
+		}
+		if !nacosClient.IsForbidden(err) {
+			// nacos还未就绪，由后续的检查步骤报告
+			c.logger.V(0).Info("skip auth, nacos is not reachable", "nacos", nacos.Name, "err", err.Error())
+			return 0, nil
+		}
+	}
+	if used == "" {
+		return 0, myErrors.New(myErrors.CODE_AUTH_FAILED, "login as %s failed, password in secret %s is out of date",
+			NACOS_ADMIN_USER, secret.Name)
+	}
+
+	if used != desired {
+		cli := anonymous.WithCredentials(&nacosClient.Credentials{Username: NACOS_ADMIN_USER, Password: used})
+		if err := cli.UpdateUserPassword(ip, NACOS_ADMIN_USER, desired); err != nil {
+			return 0, myErrors.NewErrWithCode(err, myErrors.CODE_AUTH_FAILED)
+		}
+		c.logger.V(0).Info("admin password updated", "nacos", nacos.Name)
+	}
+	if current == desired && adminPasswordRotated(secret) {
+		return 0, nil
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Data[AUTH_SECRET_ADMIN_PASSWORD] = []byte(desired)
+	secret.Annotations[ANNOTATION_ADMIN_PASSWORD_ROTATED] = "true"
+	if err := c.k8sService.UpdateSecret(nacos.Namespace, secret); err != nil {
+		return 0, myErrors.NewErr(err)
+	}
+	return 0, nil
+}
+
+func (c *AuthClient) desiredPassword(nacos *nacosgroupv1alpha1.Nacos, current string) (string, error) {
+	ref := nacos.Spec.Auth.AdminPasswordSecretRef
+	if ref == nil {
+		return current, nil
+	}
+	secret, err := c.k8sService.GetSecret(nacos.Namespace, ref.Name)
+	if err != nil {
+		return "", myErrors.New(myErrors.CODE_PARAMETER_ERROR, "get secret %s failed: %s", ref.Name, err.Error())
+	}
+	password, ok := secret.Data[ref.Key]
+	if !ok || len(password) == 0 {
+		return "", myErrors.New(myErrors.CODE_PARAMETER_ERROR, "secret %s has no key %s", ref.Name, ref.Key)
+	}
+	return string(password), nil
+}
@@ -0,0 +1,548 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"nacos.io/nacos-operator/pkg/backup"
+	myErrors "nacos.io/nacos-operator/pkg/errors"
+	"nacos.io/nacos-operator/pkg/metrics"
+	"nacos.io/nacos-operator/pkg/service/k8s"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// 每个配置默认导出的历史版本数
+const BACKUP_HISTORY_LIMIT = 10
+
+// 备份/恢复等待nacos进入Running的最长时间，从cr创建开始计算，超时后标记为Failed
+const BACKUP_WAIT_TIMEOUT = time.Hour
+
+// 备份/恢复job的失败重试次数
+const BACKUP_JOB_BACKOFF_LIMIT = 2
+
+// 备份/恢复job的最长运行时间，超时后job失败，避免卡住的job一直处于Running
+const BACKUP_JOB_DEADLINE_SECONDS = 3600
+
+// operator镜像以nonroot用户运行，需要设置fsGroup才能写入pvc
+const BACKUP_FS_GROUP = 65532
+
+// operator所在pod中的容器名称和启动命令，用于获取operator镜像
+const OPERATOR_CONTAINER_NAME = "manager"
+const OPERATOR_COMMAND = "/manager"
+
+// 定时备份创建的备份带有该finalizer，删除备份时同时删除备份文件
+const BACKUP_CLEANUP_FINALIZER = "nacos.io/backup-cleanup"
+
+type IBackupClient interface {
+	MakeBackup(nacosBackup *nacosgroupv1alpha1.NacosBackup) (time.Duration, error)
+	MakeRestore(restore *nacosgroupv1alpha1.NacosRestore) (time.Duration, error)
+}
+
+type BackupClient struct {
+	k8sService   k8s.Services
+	logger       log.Logger
+	scheme       *runtime.Scheme
+	client       client.Client
+	kindClient   *KindClient
+	statusClient *StatusClient
+
+	imageLock sync.Mutex
+	image     string
+}
+
+func NewBackupClient(logger log.Logger, k8sService k8s.Services, scheme *runtime.Scheme, client client.Client,
+	kindClient *KindClient, statusClient *StatusClient) *BackupClient {
+	return &BackupClient{
+		k8sService:   k8sService,
+		logger:       logger,
+		scheme:       scheme,
+		client:       client,
+		kindClient:   kindClient,
+		statusClient: statusClient,
+	}
+}
+
+// MakeBackup 创建执行备份的job，并根据job的状态更新备份状态
+func (c *BackupClient) MakeBackup(nacosBackup *nacosgroupv1alpha1.NacosBackup) (time.Duration, error) {
+	status := &nacosBackup.Status
+	if nacosBackup.DeletionTimestamp != nil {
+		return c.cleanupBackup(nacosBackup)
+	}
+	switch status.Phase {
+	case nacosgroupv1alpha1.BackupPhaseSucceeded:
+		// operator重启后重新填充指标
+		if status.CompletionTime != nil {
+			metrics.SetBackupLastSuccess(nacosBackup.Namespace, nacosBackup.Spec.NacosName, status.CompletionTime.Time)
+		}
+		return 0, nil
+	case nacosgroupv1alpha1.BackupPhaseFailed:
+		return 0, nil
+	}
+	if err := validateStorage(&nacosBackup.Spec.Storage); err != nil {
+		return 0, c.failBackup(nacosBackup, err.Error())
+	}
+	nacos, requeue, err := c.getRunningNacos(nacosBackup.Namespace, nacosBackup.Spec.NacosName, nacosBackup.CreationTimestamp)
+	if err != nil || requeue > 0 {
+		if myErr, ok := err.(*myErrors.Err); ok {
+			return 0, c.failBackup(nacosBackup, myErr.Msg)
+		}
+		return requeue, err
+	}
+
+	if status.Path == "" {
+		status.Path = path.Join(nacosBackup.Namespace, nacos.Name, nacosBackup.Name+".tar.gz")
+		status.Location = storageLocation(&nacosBackup.Spec.Storage, status.Path)
+	}
+	historyLimit := int32(BACKUP_HISTORY_LIMIT)
+	if nacosBackup.Spec.HistoryLimit != nil {
+		historyLimit = *nacosBackup.Spec.HistoryLimit
+	}
+	env := append(c.nacosEnv(nacos),
+		v1.EnvVar{Name: backup.ENV_PATH, Value: status.Path},
+		v1.EnvVar{Name: backup.ENV_HISTORY_LIMIT, Value: fmt.Sprintf("%d", historyLimit)},
+	)
+	job, err := c.buildJob(nacosBackup, nacos.Name, backup.COMMAND_BACKUP, nacosBackup.Name+"-backup",
+		nacosBackup.Spec.Image, &nacosBackup.Spec.Storage, env)
+	if err != nil {
+		return 0, err
+	}
+	done, msg, err := c.ensureJob(job)
+	if err != nil {
+		return 0, err
+	}
+	return c.updateBackupStatus(nacosBackup, done, msg)
+}
+
+// MakeRestore 创建执行恢复的job，并根据job的状态更新恢复状态
+func (c *BackupClient) MakeRestore(restore *nacosgroupv1alpha1.NacosRestore) (time.Duration, error) {
+	status := &restore.Status
+	if status.Phase == nacosgroupv1alpha1.BackupPhaseSucceeded || status.Phase == nacosgroupv1alpha1.BackupPhaseFailed {
+		return 0, nil
+	}
+
+	storage, filePath := restore.Spec.Storage, restore.Spec.Path
+	if restore.Spec.BackupName != "" {
+		nacosBackup := &nacosgroupv1alpha1.NacosBackup{}
+		if err := c.client.Get(context.TODO(), types.NamespacedName{Namespace: restore.Namespace, Name: restore.Spec.BackupName}, nacosBackup); err != nil {
+			if errors.IsNotFound(err) {
+				return 0, c.failRestore(restore, fmt.Sprintf("backup %s not found", restore.Spec.BackupName))
+			}
+			return 0, err
+		}
+		switch nacosBackup.Status.Phase {
+		case nacosgroupv1alpha1.BackupPhaseSucceeded:
+		case nacosgroupv1alpha1.BackupPhaseFailed:
+			return 0, c.failRestore(restore, fmt.Sprintf("backup %s failed", nacosBackup.Name))
+		default:
+			// 等待备份完成
+			return REQUEUE_INTERVAL, nil
+		}
+		storage, filePath = &nacosBackup.Spec.Storage, nacosBackup.Status.Path
+	}
+	if storage == nil || filePath == "" {
+		return 0, c.failRestore(restore, "backupName or storage and path is required")
+	}
+	if err := validateStorage(storage); err != nil {
+		return 0, c.failRestore(restore, err.Error())
+	}
+	switch restore.Spec.Policy {
+	case "", nacosgroupv1alpha1.RestorePolicyOverwrite, nacosgroupv1alpha1.RestorePolicySkip:
+	default:
+		return 0, c.failRestore(restore, fmt.Sprintf("unknown policy %s", restore.Spec.Policy))
+	}
+
+	nacos, requeue, err := c.getRunningNacos(restore.Namespace, restore.Spec.NacosName, restore.CreationTimestamp)
+	if err != nil || requeue > 0 {
+		if myErr, ok := err.(*myErrors.Err); ok {
+			return 0, c.failRestore(restore, myErr.Msg)
+		}
+		return requeue, err
+	}
+
+	status.Location = storageLocation(storage, filePath)
+	env := append(c.nacosEnv(nacos),
+		v1.EnvVar{Name: backup.ENV_PATH, Value: filePath},
+		v1.EnvVar{Name: backup.ENV_RESTORE_POLICY, Value: restore.Spec.Policy},
+	)
+	job, err := c.buildJob(restore, nacos.Name, backup.COMMAND_RESTORE, restore.Name+"-restore", restore.Spec.Image, storage, env)
+	if err != nil {
+		return 0, err
+	}
+	done, msg, err := c.ensureJob(job)
+	if err != nil {
+		return 0, err
+	}
+	return c.updateRestoreStatus(restore, done, msg)
+}
+
+// getRunningNacos 目标实例不存在返回参数错误，未就绪时等待，从created开始超过BACKUP_WAIT_TIMEOUT后返回错误
+func (c *BackupClient) getRunningNacos(namespace string, name string, created metav1.Time) (*nacosgroupv1alpha1.Nacos, time.Duration, error) {
+	nacos := &nacosgroupv1alpha1.Nacos{}
+	if err := c.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, nacos); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, 0, myErrors.New(myErrors.CODE_PARAMETER_ERROR, "nacos %s not found", name)
+		}
+		return nil, 0, err
+	}
+	if nacos.Status.Phase != nacosgroupv1alpha1.PhaseRunning {
+		if waited := time.Since(created.Time); !created.IsZero() && waited > BACKUP_WAIT_TIMEOUT {
+			return nil, 0, myErrors.New(myErrors.CODE_BACKUP_FAILED, "nacos %s is not Running after waiting %s, phase is %s", name, waited.Round(time.Second), nacos.Status.Phase)
+		}
+		return nil, REQUEUE_INTERVAL, nil
+	}
+	return nacos, 0, nil
+}
+
+func (c *BackupClient) ensureJob(job *batchv1.Job) (bool, string, error) {
+	return ensureJob(c.k8sService, job)
+}
+
+// ensureJob 创建job并返回是否结束，结束时msg为空表示成功
+func ensureJob(k8sService k8s.Services, job *batchv1.Job) (bool, string, error) {
+	if err := k8sService.CreateIfNotExistsJob(job.Namespace, job); err != nil {
+		return false, "", err
+	}
+	stored, err := k8sService.GetJob(job.Namespace, job.Name)
+	if err != nil {
+		return false, "", err
+	}
+	for _, condition := range stored.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, "", nil
+		case batchv1.JobFailed:
+			return true, fmt.Sprintf("job %s failed: %s", job.Name, condition.Message), nil
+		}
+	}
+	return false, "", nil
+}
+
+func (c *BackupClient) updateBackupStatus(nacosBackup *nacosgroupv1alpha1.NacosBackup, done bool, msg string) (time.Duration, error) {
+	status := &nacosBackup.Status
+	if !done {
+		if status.Phase == nacosgroupv1alpha1.BackupPhaseRunning {
+			return 0, nil
+		}
+		now := metav1.Now()
+		status.Phase = nacosgroupv1alpha1.BackupPhaseRunning
+		status.StartTime = &now
+		return 0, c.client.Status().Update(context.TODO(), nacosBackup)
+	}
+	if msg != "" {
+		return 0, c.failBackup(nacosBackup, msg)
+	}
+	now := metav1.Now()
+	status.Phase = nacosgroupv1alpha1.BackupPhaseSucceeded
+	status.CompletionTime = &now
+	c.logger.V(0).Info("backup succeeded", "namespace", nacosBackup.Namespace, "name", nacosBackup.Name, "location", status.Location)
+	if err := c.client.Status().Update(context.TODO(), nacosBackup); err != nil {
+		return 0, err
+	}
+	metrics.SetBackupLastSuccess(nacosBackup.Namespace, nacosBackup.Spec.NacosName, now.Time)
+	return 0, c.recordNacosEvent(nacosBackup, myErrors.CODE_BACKUP, fmt.Sprintf("backup %s succeeded: %s", nacosBackup.Name, status.Location), true)
+}
+
+func (c *BackupClient) failBackup(nacosBackup *nacosgroupv1alpha1.NacosBackup, msg string) error {
+	now := metav1.Now()
+	nacosBackup.Status.Phase = nacosgroupv1alpha1.BackupPhaseFailed
+	nacosBackup.Status.CompletionTime = &now
+	nacosBackup.Status.Message = msg
+	c.logger.V(0).Info("backup failed", "namespace", nacosBackup.Namespace, "name", nacosBackup.Name, "msg", msg)
+	if err := c.client.Status().Update(context.TODO(), nacosBackup); err != nil {
+		return err
+	}
+	return c.recordNacosEvent(nacosBackup, myErrors.CODE_BACKUP_FAILED, fmt.Sprintf("backup %s failed: %s", nacosBackup.Name, msg), false)
+}
+
+// recordNacosEvent 把备份结果记录到nacos的status.event中，nacos不存在时忽略
+func (c *BackupClient) recordNacosEvent(nacosBackup *nacosgroupv1alpha1.NacosBackup, code int, msg string, status bool) error {
+	nacos := &nacosgroupv1alpha1.Nacos{}
+	if err := c.client.Get(context.TODO(), types.NamespacedName{Namespace: nacosBackup.Namespace, Name: nacosBackup.Spec.NacosName}, nacos); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return c.statusClient.RecordEvent(nacos, code, msg, status)
+}
+
+// cleanupBackup 删除带有清理finalizer的备份时，先通过job删除备份文件
+func (c *BackupClient) cleanupBackup(nacosBackup *nacosgroupv1alpha1.NacosBackup) (time.Duration, error) {
+	if !containsString(nacosBackup.Finalizers, BACKUP_CLEANUP_FINALIZER) {
+		return 0, nil
+	}
+	if nacosBackup.Status.Path != "" && validateStorage(&nacosBackup.Spec.Storage) == nil {
+		job, err := c.buildJob(nacosBackup, nacosBackup.Spec.NacosName, backup.COMMAND_DELETE, nacosBackup.Name+"-delete",
+			nacosBackup.Spec.Image, &nacosBackup.Spec.Storage, []v1.EnvVar{{Name: backup.ENV_PATH, Value: nacosBackup.Status.Path}})
+		if err != nil {
+			return 0, err
+		}
+		done, msg, err := c.ensureJob(job)
+		if err != nil {
+			return 0, err
+		}
+		if !done {
+			return REQUEUE_INTERVAL, nil
+		}
+		// 删除失败不能一直阻塞备份的删除，记录下来由用户处理
+		if msg != "" {
+			c.logger.V(0).Info("delete backup file failed", "namespace", nacosBackup.Namespace, "name", nacosBackup.Name,
+				"location", nacosBackup.Status.Location, "msg", msg)
+		}
+	}
+	nacosBackup.Finalizers = removeString(nacosBackup.Finalizers, BACKUP_CLEANUP_FINALIZER)
+	return 0, c.client.Update(context.TODO(), nacosBackup)
+}
+
+func (c *BackupClient) updateRestoreStatus(restore *nacosgroupv1alpha1.NacosRestore, done bool, msg string) (time.Duration, error) {
+	status := &restore.Status
+	if !done {
+		if status.Phase == nacosgroupv1alpha1.BackupPhaseRunning {
+			return 0, nil
+		}
+		now := metav1.Now()
+		status.Phase = nacosgroupv1alpha1.BackupPhaseRunning
+		status.StartTime = &now
+		return 0, c.client.Status().Update(context.TODO(), restore)
+	}
+	if msg != "" {
+		return 0, c.failRestore(restore, msg)
+	}
+	now := metav1.Now()
+	status.Phase = nacosgroupv1alpha1.BackupPhaseSucceeded
+	status.CompletionTime = &now
+	c.logger.V(0).Info("restore succeeded", "namespace", restore.Namespace, "name", restore.Name, "location", status.Location)
+	return 0, c.client.Status().Update(context.TODO(), restore)
+}
+
+func (c *BackupClient) failRestore(restore *nacosgroupv1alpha1.NacosRestore, msg string) error {
+	now := metav1.Now()
+	restore.Status.Phase = nacosgroupv1alpha1.BackupPhaseFailed
+	restore.Status.CompletionTime = &now
+	restore.Status.Message = msg
+	c.logger.V(0).Info("restore failed", "namespace", restore.Namespace, "name", restore.Name, "msg", msg)
+	return c.client.Status().Update(context.TODO(), restore)
+}
+
+// nacosEnv 访问nacos所需的环境变量
+func (c *BackupClient) nacosEnv(nacos *nacosgroupv1alpha1.Nacos) []v1.EnvVar {
+	env := []v1.EnvVar{
+		{Name: backup.ENV_NACOS_ADDRESS, Value: c.kindClient.generateAccessAddress(nacos)},
+		{Name: backup.ENV_NACOS_NAME, Value: nacos.Name},
+		{Name: backup.ENV_NACOS_NAMESPACE, Value: nacos.Namespace},
+		{Name: backup.ENV_NACOS_PORT, Value: fmt.Sprintf("%d", nacos.ServerPort())},
+		{Name: backup.ENV_NACOS_CONTEXT_PATH, Value: nacos.ServerContextPath()},
+	}
+	// 和operator使用相同的账号
+	if ref := nacos.Spec.Auth.CredentialsSecretRef; ref != nil {
+		env = append(env,
+			v1.EnvVar{Name: backup.ENV_NACOS_USERNAME, ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: *ref, Key: CREDENTIALS_USERNAME_KEY,
+			}}},
+			v1.EnvVar{Name: backup.ENV_NACOS_PASSWORD, ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: *ref, Key: CREDENTIALS_PASSWORD_KEY,
+			}}},
+		)
+	} else if nacos.Spec.Auth.Enabled {
+		password := v1.EnvVar{Name: backup.ENV_NACOS_PASSWORD, ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: c.kindClient.authSelector(nacos, AUTH_SECRET_ADMIN_PASSWORD),
+		}}
+		// 管理员密码还未修改时使用初始密码
+		if secret, err := c.k8sService.GetSecret(nacos.Namespace, c.kindClient.generateAuthSecretName(nacos)); err == nil && !adminPasswordRotated(secret) {
+			password = v1.EnvVar{Name: backup.ENV_NACOS_PASSWORD, Value: NACOS_DEFAULT_PASSWORD}
+		}
+		env = append(env, v1.EnvVar{Name: backup.ENV_NACOS_USERNAME, Value: NACOS_ADMIN_USER}, password)
+	}
+	// 开启tls时使用证书中的ca校验服务端
+	if nacos.Spec.TLS.Enabled {
+		env = append(env, v1.EnvVar{Name: backup.ENV_NACOS_CA, ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: c.kindClient.tlsSelector(nacos, TLS_CA_KEY),
+		}})
+	}
+	return env
+}
+
+// buildJob 使用operator镜像以子命令方式执行备份/恢复/删除
+func (c *BackupClient) buildJob(owner metav1.Object, nacosName string, command string, name string,
+	image string, storage *nacosgroupv1alpha1.BackupStorage, env []v1.EnvVar) (*batchv1.Job, error) {
+	image, err := c.operatorImage(image)
+	if err != nil {
+		return nil, err
+	}
+	labels := c.kindClient.generateLabels(nacosName, command)
+
+	volumes := []v1.Volume{}
+	volumeMounts := []v1.VolumeMount{}
+	if storage.PVC != nil {
+		env = append(env,
+			v1.EnvVar{Name: backup.ENV_STORAGE, Value: backup.STORAGE_PVC},
+			v1.EnvVar{Name: backup.ENV_DIR, Value: path.Join(backup.DEFAULT_DIR, storage.PVC.SubPath)},
+		)
+		volumes = append(volumes, v1.Volume{
+			Name: "backup",
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: storage.PVC.ClaimName},
+			},
+		})
+		volumeMounts = append(volumeMounts, v1.VolumeMount{Name: "backup", MountPath: backup.DEFAULT_DIR})
+	} else {
+		s3 := storage.S3
+		env = append(env,
+			v1.EnvVar{Name: backup.ENV_STORAGE, Value: backup.STORAGE_S3},
+			v1.EnvVar{Name: backup.ENV_S3_ENDPOINT, Value: s3.Endpoint},
+			v1.EnvVar{Name: backup.ENV_S3_BUCKET, Value: s3.Bucket},
+			v1.EnvVar{Name: backup.ENV_S3_REGION, Value: s3.Region},
+			v1.EnvVar{Name: backup.ENV_S3_INSECURE, Value: fmt.Sprintf("%t", s3.Insecure)},
+			v1.EnvVar{Name: backup.ENV_S3_ACCESS_KEY, ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: s3.CredentialsSecret}, Key: "accessKey"}}},
+			v1.EnvVar{Name: backup.ENV_S3_SECRET_KEY, ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: s3.CredentialsSecret}, Key: "secretKey"}}},
+		)
+		// s3的前缀放在路径中
+		for i := range env {
+			if env[i].Name == backup.ENV_PATH {
+				env[i].Value = path.Join(s3.Prefix, env[i].Value)
+			}
+		}
+	}
+
+	backoffLimit := int32(BACKUP_JOB_BACKOFF_LIMIT)
+	deadline := int64(BACKUP_JOB_DEADLINE_SECONDS)
+	fsGroup := int64(BACKUP_FS_GROUP)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: owner.GetNamespace(),
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					RestartPolicy:   v1.RestartPolicyNever,
+					SecurityContext: &v1.PodSecurityContext{FSGroup: &fsGroup},
+					Volumes:         volumes,
+					Containers: []v1.Container{
+						{
+							Name:         command,
+							Image:        image,
+							Command:      []string{"/manager", command},
+							Env:          env,
+							VolumeMounts: volumeMounts,
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(owner, job, c.scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// operatorImage 依次使用cr中指定的镜像、环境变量OPERATOR_IMAGE、operator所在pod中manager容器的镜像。
+// 只缓存成功获取的镜像，失败时下次重新获取
+func (c *BackupClient) operatorImage(image string) (string, error) {
+	if image != "" {
+		return image, nil
+	}
+	c.imageLock.Lock()
+	defer c.imageLock.Unlock()
+	if c.image != "" {
+		return c.image, nil
+	}
+	if image := os.Getenv("OPERATOR_IMAGE"); image != "" {
+		c.image = image
+		return c.image, nil
+	}
+	namespace, name := os.Getenv("POD_NAMESPACE"), os.Getenv("POD_NAME")
+	if namespace == "" || name == "" {
+		return "", myErrors.New(myErrors.CODE_PARAMETER_ERROR, "can not detect operator image, set spec.image or OPERATOR_IMAGE")
+	}
+	pod, err := c.k8sService.GetPod(namespace, name)
+	if err != nil {
+		return "", fmt.Errorf("get operator pod %s/%s failed: %s", namespace, name, err.Error())
+	}
+	container := managerContainer(pod.Spec.Containers)
+	if container == nil {
+		return "", myErrors.New(myErrors.CODE_PARAMETER_ERROR, "can not find container %s in operator pod %s, set spec.image or OPERATOR_IMAGE", OPERATOR_CONTAINER_NAME, name)
+	}
+	c.image = container.Image
+	return c.image, nil
+}
+
+// managerContainer 按名称查找operator容器，名称被修改时(例如helm chart)按启动命令查找，避免选中sidecar
+func managerContainer(containers []v1.Container) *v1.Container {
+	for i := range containers {
+		if containers[i].Name == OPERATOR_CONTAINER_NAME {
+			return &containers[i]
+		}
+	}
+	for i := range containers {
+		if command := containers[i].Command; len(command) > 0 && command[0] == OPERATOR_COMMAND {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+func validateStorage(storage *nacosgroupv1alpha1.BackupStorage) error {
+	if (storage.PVC == nil) == (storage.S3 == nil) {
+		return fmt.Errorf("exactly one of storage.pvc and storage.s3 is required")
+	}
+	if storage.PVC != nil && storage.PVC.ClaimName == "" {
+		return fmt.Errorf("storage.pvc.claimName is required")
+	}
+	if storage.S3 != nil && (storage.S3.Endpoint == "" || storage.S3.Bucket == "" || storage.S3.CredentialsSecret == "") {
+		return fmt.Errorf("storage.s3.endpoint, bucket and credentialsSecret are required")
+	}
+	return nil
+}
+
+func storageLocation(storage *nacosgroupv1alpha1.BackupStorage, filePath string) string {
+	if storage.PVC != nil {
+		return fmt.Sprintf("pvc://%s/%s", storage.PVC.ClaimName, strings.TrimPrefix(path.Join(storage.PVC.SubPath, filePath), "/"))
+	}
+	return fmt.Sprintf("s3://%s/%s", storage.S3.Bucket, strings.TrimPrefix(path.Join(storage.S3.Prefix, filePath), "/"))
+}
+
+func containsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(slice []string, s string) []string {
+	result := []string{}
+	for _, item := range slice {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	myErrors "nacos.io/nacos-operator/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeNacosGetter 只实现Get，返回指定phase的nacos
+type fakeNacosGetter struct {
+	client.Client
+	phase nacosgroupv1alpha1.Phase
+}
+
+func (f *fakeNacosGetter) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	nacos := obj.(*nacosgroupv1alpha1.Nacos)
+	nacos.Name = key.Name
+	nacos.Namespace = key.Namespace
+	nacos.Status.Phase = f.phase
+	return nil
+}
+
+func TestGetRunningNacos(t *testing.T) {
+	tests := []struct {
+		name    string
+		phase   nacosgroupv1alpha1.Phase
+		created time.Time
+		running bool
+		failed  bool
+	}{
+		{"running", nacosgroupv1alpha1.PhaseRunning, time.Now().Add(-2 * BACKUP_WAIT_TIMEOUT), true, false},
+		{"waiting", nacosgroupv1alpha1.PhaseUpdating, time.Now(), false, false},
+		{"timeout", nacosgroupv1alpha1.PhaseFailed, time.Now().Add(-BACKUP_WAIT_TIMEOUT - time.Minute), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &BackupClient{client: &fakeNacosGetter{phase: tt.phase}}
+			nacos, requeue, err := c.getRunningNacos("default", "nacos", metav1.NewTime(tt.created))
+			if (nacos != nil) != tt.running {
+				t.Errorf("nacos = %v, want running %v", nacos, tt.running)
+			}
+			if e, ok := err.(*myErrors.Err); ok != tt.failed || (ok && e.Code != myErrors.CODE_BACKUP_FAILED) {
+				t.Errorf("err = %v, want failed %v", err, tt.failed)
+			}
+			if (requeue > 0) != (!tt.running && !tt.failed) {
+				t.Errorf("requeue = %v", requeue)
+			}
+		})
+	}
+}
+
+func TestManagerContainer(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []v1.Container
+		want       string
+	}{
+		{"by name", []v1.Container{
+			{Name: "kube-rbac-proxy", Image: "proxy"},
+			{Name: OPERATOR_CONTAINER_NAME, Image: "operator"},
+		}, "operator"},
+		{"by command", []v1.Container{
+			{Name: "istio-proxy", Image: "proxy"},
+			{Name: "nacos-operator", Image: "operator", Command: []string{OPERATOR_COMMAND}},
+		}, "operator"},
+		{"not found", []v1.Container{{Name: "istio-proxy", Image: "proxy"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := managerContainer(tt.containers)
+			got := ""
+			if container != nil {
+				got = container.Image
+			}
+			if got != tt.want {
+				t.Errorf("managerContainer() image = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,95 +1,254 @@
 package operator
 
 import (
+	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
 	log "github.com/go-logr/logr"
 	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
 	myErrors "nacos.io/nacos-operator/pkg/errors"
+	"nacos.io/nacos-operator/pkg/metrics"
 	"nacos.io/nacos-operator/pkg/service/k8s"
 	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
 )
 
+// status.members中成员的角色
+const (
+	MEMBER_ROLE_LEADER   = "leader"
+	MEMBER_ROLE_FOLLOWER = "follower"
+)
+
 type ICheckClient interface {
-	Check(nacos *nacosgroupv1alpha1.Nacos)
+	CheckKind(nacos *nacosgroupv1alpha1.Nacos) ([]corev1.Pod, error)
+	CheckNacos(nacos *nacosgroupv1alpha1.Nacos, pods []corev1.Pod) error
 }
 
 type CheckClient struct {
-	k8sService  k8s.Services
-	logger      log.Logger
-	nacosClient nacosClient.NacosClient
+	k8sService k8s.Services
+	logger     log.Logger
+	kindClient *KindClient
+	recorder   record.EventRecorder
+	lock       sync.Mutex
+	// 已经记录过结束事件的mysql初始化job，按uid区分重新创建的job
+	finishedJobs map[types.UID]bool
 }
 
-func NewCheckClient(logger log.Logger, k8sService k8s.Services) *CheckClient {
+func NewCheckClient(logger log.Logger, k8sService k8s.Services, kindClient *KindClient, recorder record.EventRecorder) *CheckClient {
 	return &CheckClient{
-		k8sService: k8sService,
-		logger:     logger,
+		k8sService:   k8sService,
+		logger:       logger,
+		kindClient:   kindClient,
+		recorder:     recorder,
+		finishedJobs: map[types.UID]bool{},
 	}
 }
 
-func (c *CheckClient) CheckKind(nacos *nacosgroupv1alpha1.Nacos) []corev1.Pod {
+func (c *CheckClient) CheckKind(nacos *nacosgroupv1alpha1.Nacos) ([]corev1.Pod, error) {
 	// 保证ss数量和cr副本数匹配
 	ss, err := c.k8sService.GetStatefulSet(nacos.Namespace, nacos.Name)
-	myErrors.EnsureNormal(err)
+	if err != nil {
+		return nil, myErrors.NewErr(err)
+	}
 
-	if *ss.Spec.Replicas != *nacos.Spec.Replicas {
-		panic(myErrors.New(myErrors.CODE_ERR_UNKNOW, "cr replicas is not equal ss replicas"))
+	replicas := memberReplicas(nacos)
+	if *ss.Spec.Replicas != replicas {
+		return nil, myErrors.New(myErrors.CODE_ERR_UNKNOW, "cluster members is not equal ss replicas")
+	}
 
+	// mysql模式下检查初始化job是否失败，并记录job结束的事件；在检查pod之前更新DatabaseInitialized
+	var jobErr error
+	if nacos.Spec.Database.TypeDatabase == "mysql" {
+		jobErr = c.checkMysqlJob(nacos)
+	} else {
+		setCondition(nacos, nacosgroupv1alpha1.ConditionDatabaseInitialized, true, "EmbeddedDatabase", "")
 	}
 
+	// 只统计statefulset自己的pod，未ready的原因记录到Available和Degraded中
+	podList, err := c.k8sService.GetStatefulSetPods(nacos.Namespace, nacos.Name)
+	if err != nil {
+		return nil, myErrors.NewErr(err)
+	}
+	pods := []corev1.Pod{}
+	reasons := []string{}
+	for _, pod := range podList.Items {
+		if k8s.IsPodReady(&pod) {
+			pods = append(pods, pod)
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %s", pod.Name, k8s.PodNotReadyReason(&pod)))
+	}
+	message := strings.Join(reasons, "; ")
+
 	// 检查正常的pod数量，根据实际情况。如果单实例，必须要有1个;集群要1/2以上
-	pods, err := c.k8sService.GetStatefulSetReadPod(nacos.Namespace, nacos.Name)
-	if len(pods) < (int(*nacos.Spec.Replicas)+1)/2 {
-		panic(myErrors.New(myErrors.CODE_ERR_UNKNOW, "The number of ready pods is too less"))
-	} else if len(pods) != int(*nacos.Spec.Replicas) {
-		c.logger.V(0).Info("pod num is not right")
+	metrics.SetPodsReady(nacos.Namespace, nacos.Name, len(pods))
+	if len(pods) < (int(replicas)+1)/2 {
+		setCondition(nacos, nacosgroupv1alpha1.ConditionAvailable, false, "QuorumLost", message)
+		setCondition(nacos, nacosgroupv1alpha1.ConditionDegraded, true, "QuorumLost", message)
+		c.recorder.Eventf(nacos, corev1.EventTypeWarning, EVENT_REASON_QUORUM_LOST, "%d/%d pods are ready: %s", len(pods), replicas, message)
+		return nil, myErrors.New(myErrors.CODE_POD_NOT_READY, "The number of ready pods is too less: %s", message)
+	}
+	setCondition(nacos, nacosgroupv1alpha1.ConditionAvailable, true, "QuorumReady", fmt.Sprintf("%d/%d pods are ready", len(pods), replicas))
+	if len(pods) != int(replicas) {
+		c.logger.V(0).Info("pod num is not right", "notReady", reasons)
+		setCondition(nacos, nacosgroupv1alpha1.ConditionDegraded, true, "PodsNotReady", message)
+	} else {
+		setCondition(nacos, nacosgroupv1alpha1.ConditionDegraded, false, "AllPodsReady", "")
+	}
+
+	if jobErr != nil {
+		return nil, jobErr
+	}
+	return pods, nil
+}
+
+func (c *CheckClient) checkMysqlJob(nacos *nacosgroupv1alpha1.Nacos) error {
+	job, err := c.k8sService.GetJob(nacos.Namespace, nacos.Name+"-mysql-sql-init")
+	if err != nil {
+		// job还未创建或者已被清理，交给MakeEnsure处理
+		return nil
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			setCondition(nacos, nacosgroupv1alpha1.ConditionDatabaseInitialized, true, "JobCompleted", "")
+			c.recordJobFinished(nacos, job, corev1.EventTypeNormal, EVENT_REASON_MYSQL_INIT_SUCCEEDED, "mysql init job %s succeeded", job.Name)
+			return nil
+		case batchv1.JobFailed:
+			setCondition(nacos, nacosgroupv1alpha1.ConditionDatabaseInitialized, false, "JobFailed", condition.Message)
+			c.recordJobFinished(nacos, job, corev1.EventTypeWarning, EVENT_REASON_MYSQL_INIT_FAILED, "mysql init job %s failed: %s", job.Name, condition.Message)
+			return myErrors.New(myErrors.CODE_MYSQL_INIT_FAILED, "mysql init job failed: %s", condition.Message)
+		}
+	}
+	setCondition(nacos, nacosgroupv1alpha1.ConditionDatabaseInitialized, false, "JobRunning", "")
+	return nil
+}
+
+// recordJobFinished 每个job只记录一次结束事件，避免定期检查时重复记录
+func (c *CheckClient) recordJobFinished(nacos *nacosgroupv1alpha1.Nacos, job *batchv1.Job, eventType string, reason string, format string, args ...interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.finishedJobs[job.UID] {
+		return
 	}
-	return pods
+	c.finishedJobs[job.UID] = true
+	c.recorder.Eventf(nacos, eventType, reason, format, args...)
 }
 
-func (c *CheckClient) CheckNacos(nacos *nacosgroupv1alpha1.Nacos, pods []corev1.Pod) {
-	leader := ""
-	nacos.Status.Conditions = []nacosgroupv1alpha1.Condition{}
+func (c *CheckClient) CheckNacos(nacos *nacosgroupv1alpha1.Nacos, pods []corev1.Pod) error {
+	// 每个raft group的leader，所有节点看到的必须相同
+	leaders := map[string]string{}
+	version := nacos.Status.Version
+	model := c.kindClient.healthModel(nacos)
+	cli, err := c.kindClient.nacosClientFor(nacos)
+	if err != nil {
+		return err
+	}
 	// 检查nacos是否访问通
-	for _, pod := range pods {
-		servers, err := c.nacosClient.GetClusterNodes(pod.Status.PodIP)
-		myErrors.EnsureNormalMyError(err, myErrors.CODE_CLUSTER_FAILE)
-		// 确保cr中实例个数和server数量相同
-		myErrors.EnsureEqual(len(servers.Servers), int(*nacos.Spec.Replicas), myErrors.CODE_CLUSTER_FAILE, "server num is not equal")
+	for i, pod := range pods {
+		servers, err := model.ClusterNodes(cli, pod.Status.PodIP)
+		if err != nil {
+			return myErrors.NewErrWithCode(err, myErrors.CODE_CLUSTER_FAILE)
+		}
+		// 以第一个pod看到的集群信息作为指标和status.members，在检查之前记录，异常的节点状态也能导出
+		if i == 0 {
+			previous, leader := observeServers(nacos, model, servers)
+			if previous != "" {
+				c.recorder.Eventf(nacos, corev1.EventTypeNormal, EVENT_REASON_LEADER_CHANGED, "raft leader of %s changed from %s to %s", model.RaftGroups()[0], previous, leader)
+			}
+			nacos.Status.Members = buildMembers(model, servers, leader, pods)
+		}
+		// 确保集群成员数和server数量相同
+		if len(servers.Servers) != int(memberReplicas(nacos)) {
+			return myErrors.New(myErrors.CODE_NODE_NOT_MATCH, "server num is not equal: %d, %d", len(servers.Servers), memberReplicas(nacos))
+		}
 		for _, svc := range servers.Servers {
-			myErrors.EnsureEqual(svc.State, "UP", myErrors.CODE_CLUSTER_FAILE, "node is not up")
-			if leader != "" {
+			if svc.State != "UP" {
+				return myErrors.New(myErrors.CODE_NODE_DOWN, "node is not up: %s is %s", svc.Address, svc.State)
+			}
+			for group, leader := range raftLeaders(model, svc) {
 				// 确保每个节点leader相同
-				myErrors.EnsureEqual(leader, svc.ExtendInfo.RaftMetaData.MetaDataMap.NamingPersistentService.Leader,
-					myErrors.CODE_CLUSTER_FAILE, "leader not equal")
-			} else {
-				leader = svc.ExtendInfo.RaftMetaData.MetaDataMap.NamingPersistentService.Leader
+				if expected := leaders[group]; expected != "" && leader != "" && expected != leader {
+					return myErrors.New(myErrors.CODE_LEADER_SPLIT, "leader of %s not equal: %s, %s", group, expected, leader)
+				}
+				if leader != "" {
+					leaders[group] = leader
+				}
 			}
 			nacos.Status.Version = svc.ExtendInfo.Version
 		}
+	}
 
-		condition := nacosgroupv1alpha1.Condition{
-			Status:   "true",
-			Instance: pod.Status.PodIP,
-			PodName:  pod.Name,
-			NodeName: pod.Spec.NodeName,
+	// 记录每个raft group的leader
+	groups := []string{}
+	for group := range leaders {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	nacos.Status.RaftGroups = []nacosgroupv1alpha1.RaftGroupStatus{}
+	for _, group := range groups {
+		nacos.Status.RaftGroups = append(nacos.Status.RaftGroups, nacosgroupv1alpha1.RaftGroupStatus{
+			Name:    group,
+			Leader:  leaders[group],
+			PodName: memberPodName(leaders[group], pods),
+		})
+	}
+	if version != "" && version != nacos.Status.Version {
+		c.recorder.Eventf(nacos, corev1.EventTypeNormal, EVENT_REASON_VERSION_CHANGED, "nacos version changed from %s to %s", version, nacos.Status.Version)
+	}
+	return nil
+}
+
+// buildMembers 根据节点上报的集群信息生成status.members，leader为主raft group的leader
+func buildMembers(model IHealthModel, servers nacosClient.ServersInfo, leader string, pods []corev1.Pod) []nacosgroupv1alpha1.Member {
+	leaderHost := strings.Split(leader, ":")[0]
+	members := []nacosgroupv1alpha1.Member{}
+	for _, svc := range servers.Servers {
+		host := strings.Split(svc.Address, ":")[0]
+		member := nacosgroupv1alpha1.Member{
+			Address:  svc.Address,
+			PodName:  memberPodName(svc.Address, pods),
+			Role:     MEMBER_ROLE_FOLLOWER,
+			RaftTerm: primaryRaft(model, svc).Term,
+			State:    svc.State,
 		}
-		leaderSplit := []string{}
-		if strings.Index(leader, ".") > 0 {
-			leaderSplit = strings.Split(leader, ".")
-		} else {
-			leaderSplit = strings.Split(leader, ":")
+		if leaderHost != "" && leaderHost == host {
+			member.Role = MEMBER_ROLE_LEADER
 		}
-		if len(leaderSplit) > 0 {
-			if leaderSplit[0] == pod.Name {
-				condition.Type = "leader"
-			} else {
-				condition.Type = "follower"
-			}
+		if svc.ExtendInfo.LastRefreshTime > 0 {
+			member.LastRefreshTime = metav1.NewTime(time.Unix(0, svc.ExtendInfo.LastRefreshTime*int64(time.Millisecond)))
 		}
-		nacos.Status.Conditions = append(nacos.Status.Conditions, condition)
+		members = append(members, member)
 	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Address < members[j].Address
+	})
+	return members
+}
 
+// observeServers 导出主raft group的leader、term和每个节点的状态，leader切换时返回切换前后的leader
+func observeServers(nacos *nacosgroupv1alpha1.Nacos, model IHealthModel, servers nacosClient.ServersInfo) (string, string) {
+	leader, term := "", 0
+	nodes := map[string]string{}
+	for _, svc := range servers.Servers {
+		nodes[svc.Address] = svc.State
+		raft := primaryRaft(model, svc)
+		if raft.Term >= term {
+			leader, term = raft.Leader, raft.Term
+		}
+	}
+	previous := metrics.ObserveRaft(nacos.Namespace, nacos.Name, leader, term)
+	metrics.SetNodeStates(nacos.Namespace, nacos.Name, nodes)
+	return previous, leader
 }
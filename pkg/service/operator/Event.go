@@ -0,0 +1,36 @@
+package operator
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+)
+
+// 记录到nacos对象上的k8s事件的reason，保持稳定便于过滤和告警
+const (
+	EVENT_REASON_CREATED              = "Created"
+	EVENT_REASON_UPDATED              = "Updated"
+	EVENT_REASON_RUNNING              = "Running"
+	EVENT_REASON_FAILED               = "Failed"
+	EVENT_REASON_QUORUM_LOST          = "QuorumLost"
+	EVENT_REASON_LEADER_CHANGED       = "LeaderChanged"
+	EVENT_REASON_VERSION_CHANGED      = "VersionChanged"
+	EVENT_REASON_MYSQL_INIT_SUCCEEDED = "MysqlInitSucceeded"
+	EVENT_REASON_MYSQL_INIT_FAILED    = "MysqlInitFailed"
+	EVENT_REASON_MYSQL_CLEANUP_FAILED = "MysqlCleanupFailed"
+	EVENT_REASON_HEAL                 = "Heal"
+	EVENT_REASON_HEAL_FAILED          = "HealFailed"
+	EVENT_REASON_HEAL_EXHAUSTED       = "HealExhausted"
+	EVENT_REASON_SPEC_DRIFTED         = "SpecDrifted"
+)
+
+// recordCreated 记录operator创建的资源，kind为资源类型，例如StatefulSet
+func (e *KindClient) recordCreated(nacos *nacosgroupv1alpha1.Nacos, kind string, name string) {
+	e.recorder.Eventf(nacos, corev1.EventTypeNormal, EVENT_REASON_CREATED, "created %s %s", strings.ToLower(kind), name)
+}
+
+// recordUpdated 记录operator更新的资源
+func (e *KindClient) recordUpdated(nacos *nacosgroupv1alpha1.Nacos, kind string, name string) {
+	e.recorder.Eventf(nacos, corev1.EventTypeNormal, EVENT_REASON_UPDATED, "updated %s %s", strings.ToLower(kind), name)
+}
@@ -1,21 +1,296 @@
 package operator
 
 import (
+	"fmt"
+	"time"
+
 	log "github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	myErrors "nacos.io/nacos-operator/pkg/errors"
 	"nacos.io/nacos-operator/pkg/service/k8s"
 )
 
 type IHealClient interface {
+	MakeHeal(nacos *nacosgroupv1alpha1.Nacos) error
 }
 
+// 同一个故障最多自愈的次数，超过后需要人工介入
+const HEAL_MAX_ATTEMPTS = 5
+
+// 两次自愈操作之间的最小间隔
+const HEAL_INTERVAL = time.Minute
+
 type HealClient struct {
+	k8sService   k8s.Services
+	logger       log.Logger
+	kindClient   *KindClient
+	statusClient *StatusClient
+}
+
+func NewHealClient(logger log.Logger, k8sService k8s.Services, kindClient *KindClient, statusClient *StatusClient) *HealClient {
+	return &HealClient{
+		k8sService:   k8sService,
+		logger:       logger,
+		kindClient:   kindClient,
+		statusClient: statusClient,
+	}
+}
+
+// MakeHeal 根据最近一次的异常事件诊断故障，并执行一次有限的修复操作。
+// 自愈记录保存在status.heal中，operator重启后次数限制仍然有效
+func (c *HealClient) MakeHeal(nacos *nacosgroupv1alpha1.Nacos) error {
+	event := c.lastFailedEvent(nacos)
+	if event == nil {
+		return nil
+	}
+
+	exhausted := nacos.Status.Heal != nil && nacos.Status.Heal.Exhausted
+	attempt, ok := c.allow(nacos, event.Code)
+	if !ok {
+		// 第一次达到最大次数时保存标记，之后不再重复提示
+		if !exhausted && nacos.Status.Heal.Exhausted {
+			return c.statusClient.UpdateStatus(nacos)
+		}
+		return nil
+	}
+
+	var action string
+	var err error
+	switch event.Code {
+	case myErrors.CODE_POD_NOT_READY:
+		action, err = c.healCrashLoopPod(nacos)
+	case myErrors.CODE_NODE_NOT_MATCH:
+		action, err = c.healClusterConf(nacos)
+	case myErrors.CODE_NODE_DOWN:
+		action, err = c.healNodeDown(nacos)
+	case myErrors.CODE_LEADER_SPLIT:
+		action, err = c.healLeaderSplit(nacos)
+	case myErrors.CODE_MYSQL_INIT_FAILED:
+		action, err = c.healMysqlJob(nacos)
+	}
+	if err == nil && action == "" {
+		// 其他故障先排查是否有pod一直重启
+		action, err = c.healCrashLoopPod(nacos)
+	}
+	if err != nil {
+		// 失败也计入次数，避免每次调谐都重试
+		c.record(nacos, event.Code)
+		msg := fmt.Sprintf("heal %d/%d for code %d failed: %s", attempt, HEAL_MAX_ATTEMPTS, event.Code, err.Error())
+		c.statusClient.recorder.Event(nacos, corev1.EventTypeWarning, EVENT_REASON_HEAL_FAILED, msg)
+		if e := c.statusClient.UpdateStatus(nacos); e != nil {
+			c.logger.V(0).Info("save heal status failed", "namespace", nacos.Namespace, "name", nacos.Name, "err", e.Error())
+		}
+		return err
+	}
+	if action == "" {
+		return nil
+	}
+
+	c.record(nacos, event.Code)
+	msg := fmt.Sprintf("heal %d/%d for code %d: %s", attempt, HEAL_MAX_ATTEMPTS, event.Code, action)
+	c.logger.V(0).Info("heal", "namespace", nacos.Namespace, "name", nacos.Name, "action", msg)
+	c.statusClient.updateLastEvent(nacos, myErrors.CODE_HEAL, msg, true)
+	c.statusClient.recorder.Event(nacos, corev1.EventTypeWarning, EVENT_REASON_HEAL, msg)
+	return c.statusClient.UpdateStatus(nacos)
+}
+
+// Reset 实例恢复正常后清理自愈记录，由调用方保存status
+func (c *HealClient) Reset(nacos *nacosgroupv1alpha1.Nacos) {
+	nacos.Status.Heal = nil
+}
+
+// 获取最近一次的异常事件，跳过自愈操作本身产生的事件
+func (c *HealClient) lastFailedEvent(nacos *nacosgroupv1alpha1.Nacos) *nacosgroupv1alpha1.Event {
+	for i := len(nacos.Status.Event) - 1; i >= 0; i-- {
+		event := nacos.Status.Event[i]
+		if event.Code == myErrors.CODE_HEAL {
+			continue
+		}
+		if event.Status {
+			return nil
+		}
+		return &event
+	}
+	return nil
+}
+
+// 限流：同一故障间隔HEAL_INTERVAL才能再次修复，并且最多修复HEAL_MAX_ATTEMPTS次
+func (c *HealClient) allow(nacos *nacosgroupv1alpha1.Nacos, code int) (int, bool) {
+	heal := nacos.Status.Heal
+	if heal == nil || heal.Code != code {
+		return 1, true
+	}
+	if heal.Attempts >= HEAL_MAX_ATTEMPTS {
+		if !heal.Exhausted {
+			heal.Exhausted = true
+			msg := fmt.Sprintf("heal attempts for code %d exhausted after %d tries, need manual intervention", code, heal.Attempts)
+			c.logger.V(0).Info(msg, "namespace", nacos.Namespace, "name", nacos.Name)
+			c.statusClient.recorder.Event(nacos, corev1.EventTypeWarning, EVENT_REASON_HEAL_EXHAUSTED, msg)
+		}
+		return heal.Attempts, false
+	}
+	if time.Since(heal.LastAttemptTime.Time) < HEAL_INTERVAL {
+		return heal.Attempts, false
+	}
+	return heal.Attempts + 1, true
+}
+
+func (c *HealClient) record(nacos *nacosgroupv1alpha1.Nacos, code int) {
+	if nacos.Status.Heal == nil || nacos.Status.Heal.Code != code {
+		nacos.Status.Heal = &nacosgroupv1alpha1.HealStatus{Code: code}
+	}
+	nacos.Status.Heal.Attempts++
+	nacos.Status.Heal.LastAttemptTime = metav1.Now()
+}
+
+// 删除处于CrashLoopBackOff的pod，每次只处理一个
+func (c *HealClient) healCrashLoopPod(nacos *nacosgroupv1alpha1.Nacos) (string, error) {
+	pods, err := c.k8sService.GetStatefulSetPods(nacos.Namespace, nacos.Name)
+	if err != nil {
+		return "", err
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+				return c.deletePod(nacos, pod.Name, "CrashLoopBackOff")
+			}
+		}
+	}
+	return "", nil
+}
+
+// 节点缺失：先确保cluster.conf渲染正确，再重建成员列表过期的pod
+func (c *HealClient) healClusterConf(nacos *nacosgroupv1alpha1.Nacos) (string, error) {
+	if nacos.Spec.Type == TYPE_CLUSTER {
+		cm, err := c.k8sService.GetConfigMap(nacos.Namespace, c.kindClient.generateClusterConfName(nacos))
+		if err != nil {
+			return "", err
+		}
+		desired, err := c.kindClient.buildClusterConfigMap(nacos, memberReplicas(nacos))
+		if err != nil {
+			return "", err
+		}
+		if cm.Data[CLUSTER_CONF_KEY] != desired.Data[CLUSTER_CONF_KEY] {
+			if err := c.k8sService.CreateOrUpdateConfigMap(nacos.Namespace, desired); err != nil {
+				return "", err
+			}
+			return "re-render cluster.conf", nil
+		}
+	}
+
+	cli, err := c.kindClient.nacosClientFor(nacos)
+	if err != nil {
+		return "", err
+	}
+	pods := c.readyPods(nacos)
+	for _, pod := range pods {
+		servers, err := c.kindClient.getClusterNodes(cli, nacos, pod.Status.PodIP)
+		if err != nil {
+			continue
+		}
+		if len(servers.Servers) != int(memberReplicas(nacos)) {
+			return c.deletePod(nacos, pod.Name, "stale cluster.conf")
+		}
+	}
+	return "", nil
+}
+
+// 节点DOWN：重建对应的pod
+func (c *HealClient) healNodeDown(nacos *nacosgroupv1alpha1.Nacos) (string, error) {
+	cli, err := c.kindClient.nacosClientFor(nacos)
+	if err != nil {
+		return "", err
+	}
+	// DOWN的节点通常不是ready状态，按全部pod解析地址
+	all, err := c.k8sService.GetStatefulSetPods(nacos.Namespace, nacos.Name)
+	if err != nil {
+		return "", err
+	}
+	pods := c.readyPods(nacos)
+	for _, pod := range pods {
+		servers, err := c.kindClient.getClusterNodes(cli, nacos, pod.Status.PodIP)
+		if err != nil {
+			continue
+		}
+		for _, svc := range servers.Servers {
+			if svc.State == "UP" {
+				continue
+			}
+			if name := memberPodName(svc.Address, all.Items); name != "" {
+				if _, err := c.k8sService.GetPod(nacos.Namespace, name); err == nil {
+					return c.deletePod(nacos, name, "node is "+svc.State)
+				}
+			}
+		}
+		// 只需要一个节点的视图
+		break
+	}
+	return "", nil
+}
+
+// leader分裂：以多数节点认可的leader为准，重建与其不一致的pod
+func (c *HealClient) healLeaderSplit(nacos *nacosgroupv1alpha1.Nacos) (string, error) {
+	cli, err := c.kindClient.nacosClientFor(nacos)
+	if err != nil {
+		return "", err
+	}
+	pods := c.readyPods(nacos)
+	leaders := map[string]string{}
+	votes := map[string]int{}
+	for _, pod := range pods {
+		servers, err := c.kindClient.getClusterNodes(cli, nacos, pod.Status.PodIP)
+		if err != nil || len(servers.Servers) == 0 {
+			continue
+		}
+		// 任意一个raft group的leader不同都视为分裂
+		leader := leadersKey(raftLeaders(c.kindClient.healthModel(nacos), servers.Servers[0]))
+		leaders[pod.Name] = leader
+		votes[leader]++
+	}
+
+	majority := ""
+	for leader, vote := range votes {
+		if vote > votes[majority] {
+			majority = leader
+		}
+	}
+	// 没有多数派时不做处理，避免误删
+	if votes[majority] <= len(pods)/2 {
+		return "", nil
+	}
+	for _, pod := range pods {
+		if leader, ok := leaders[pod.Name]; ok && leader != majority {
+			return c.deletePod(nacos, pod.Name, "leader split")
+		}
+	}
+	return "", nil
+}
+
+// mysql初始化失败：删除job，由MakeEnsure重新创建
+func (c *HealClient) healMysqlJob(nacos *nacosgroupv1alpha1.Nacos) (string, error) {
+	name := nacos.Name + "-mysql-sql-init"
+	if _, err := c.k8sService.GetJob(nacos.Namespace, name); err != nil {
+		return "", nil
+	}
+	if err := c.k8sService.DeleteJob(nacos.Namespace, name); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("re-run job %s", name), nil
 }
 
-func NewHealClient(logger log.Logger, k8sService k8s.Services) *HealClient {
-	return &HealClient{}
+func (c *HealClient) readyPods(nacos *nacosgroupv1alpha1.Nacos) []corev1.Pod {
+	pods, err := c.k8sService.GetStatefulSetReadPod(nacos.Namespace, nacos.Name)
+	if err != nil {
+		c.logger.V(0).Info("heal get ready pods failed", "err", err.Error())
+	}
+	return pods
 }
 
-func (c HealClient) MakeHeal(nacos *nacosgroupv1alpha1.Nacos) {
-	//TODO
+func (c *HealClient) deletePod(nacos *nacosgroupv1alpha1.Nacos, name string, reason string) (string, error) {
+	if err := c.k8sService.DeletePod(nacos.Namespace, name); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("delete pod %s (%s)", name, reason), nil
 }
@@ -0,0 +1,54 @@
+package operator
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestHealAllow(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	newClient := func() *HealClient {
+		return NewHealClient(ctrl.Log, nil, nil, &StatusClient{recorder: recorder})
+	}
+	c := newClient()
+	nacos := &nacosgroupv1alpha1.Nacos{ObjectMeta: metav1.ObjectMeta{Name: "nacos", Namespace: "default"}}
+
+	if attempt, ok := c.allow(nacos, 1); !ok || attempt != 1 {
+		t.Fatalf("first allow = %d, %v", attempt, ok)
+	}
+	c.record(nacos, 1)
+	if _, ok := c.allow(nacos, 1); ok {
+		t.Errorf("allowed within HEAL_INTERVAL")
+	}
+	if attempt, ok := c.allow(nacos, 2); !ok || attempt != 1 {
+		t.Errorf("other code allow = %d, %v", attempt, ok)
+	}
+
+	for i := 1; i < HEAL_MAX_ATTEMPTS; i++ {
+		nacos.Status.Heal.LastAttemptTime = metav1.NewTime(time.Now().Add(-HEAL_INTERVAL))
+		c.record(nacos, 1)
+	}
+	nacos.Status.Heal.LastAttemptTime = metav1.NewTime(time.Now().Add(-HEAL_INTERVAL))
+	// 记录保存在status中，operator重启后次数限制仍然有效
+	for i := 0; i < 2; i++ {
+		if _, ok := newClient().allow(nacos, 1); ok {
+			t.Errorf("allowed after %d attempts", HEAL_MAX_ATTEMPTS)
+		}
+	}
+	if !nacos.Status.Heal.Exhausted {
+		t.Errorf("heal status is not marked exhausted")
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("events = %d, want 1", len(recorder.Events))
+	}
+
+	c.Reset(nacos)
+	if attempt, ok := c.allow(nacos, 1); !ok || attempt != 1 {
+		t.Errorf("allow after reset = %d, %v", attempt, ok)
+	}
+}
@@ -0,0 +1,96 @@
+package operator
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
+)
+
+// IHealthModel 不同版本的nacos查询集群成员的接口和raft group不同，按版本选择
+type IHealthModel interface {
+	// ClusterNodes 从指定节点查询集群成员
+	ClusterNodes(cli *nacosClient.NacosClient, ip string) (nacosClient.ServersInfo, error)
+	// RaftGroups 需要检查leader一致的raft group，第一个为主group，用于判断pod的角色
+	RaftGroups() []string
+}
+
+type healthModelV1 struct{}
+
+func (healthModelV1) ClusterNodes(cli *nacosClient.NacosClient, ip string) (nacosClient.ServersInfo, error) {
+	return cli.GetClusterNodes(ip)
+}
+
+func (healthModelV1) RaftGroups() []string {
+	return []string{nacosClient.RAFT_GROUP_NAMING_PERSISTENT}
+}
+
+type healthModelV2 struct{}
+
+func (healthModelV2) ClusterNodes(cli *nacosClient.NacosClient, ip string) (nacosClient.ServersInfo, error) {
+	return cli.GetClusterNodesV2(ip)
+}
+
+func (healthModelV2) RaftGroups() []string {
+	return []string{
+		nacosClient.RAFT_GROUP_NAMING_PERSISTENT_V2,
+		nacosClient.RAFT_GROUP_INSTANCE_METADATA,
+		nacosClient.RAFT_GROUP_SERVICE_METADATA,
+	}
+}
+
+// healthModel 按版本选择健康模型
+func (e *KindClient) healthModel(nacos *nacosgroupv1alpha1.Nacos) IHealthModel {
+	if isNacos2(nacos) {
+		return healthModelV2{}
+	}
+	return healthModelV1{}
+}
+
+// raftLeaders 节点看到的每个raft group的leader，除了模型中的group，节点上报的其他group(例如nacos_config)也需要检查
+func raftLeaders(model IHealthModel, server nacosClient.ServerNode) map[string]string {
+	leaders := map[string]string{}
+	for _, group := range model.RaftGroups() {
+		leaders[group] = ""
+	}
+	for group, raft := range server.ExtendInfo.RaftMetaData.MetaDataMap {
+		leaders[group] = raft.Leader
+	}
+	return leaders
+}
+
+// primaryRaft 主group的状态，用于导出leader和term
+func primaryRaft(model IHealthModel, server nacosClient.ServerNode) nacosClient.RaftGroup {
+	return server.ExtendInfo.RaftMetaData.MetaDataMap[model.RaftGroups()[0]]
+}
+
+// leadersKey 把所有group的leader拼接起来，用于比较两个节点看到的leader是否一致
+func leadersKey(leaders map[string]string) string {
+	items := []string{}
+	for group, leader := range leaders {
+		items = append(items, group+"="+leader)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// memberPodName 成员或leader地址对应的pod，地址格式为<pod>.<headless>...:<port>或者<ip>:<port>，
+// ip地址按pod ip查找，找不到时返回空
+func memberPodName(address string, pods []corev1.Pod) string {
+	host := strings.Split(address, ":")[0]
+	if host == "" {
+		return ""
+	}
+	if net.ParseIP(host) == nil {
+		return strings.Split(host, ".")[0]
+	}
+	for _, pod := range pods {
+		if pod.Status.PodIP == host {
+			return pod.Name
+		}
+	}
+	return ""
+}
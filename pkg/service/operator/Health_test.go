@@ -0,0 +1,78 @@
+package operator
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
+)
+
+func serverNode(leaders map[string]string) nacosClient.ServerNode {
+	node := nacosClient.ServerNode{}
+	node.ExtendInfo.RaftMetaData.MetaDataMap = map[string]nacosClient.RaftGroup{}
+	for group, leader := range leaders {
+		node.ExtendInfo.RaftMetaData.MetaDataMap[group] = nacosClient.RaftGroup{Leader: leader}
+	}
+	return node
+}
+
+func TestRaftLeaders(t *testing.T) {
+	leader := "nacos-0.nacos-headless.default.svc.cluster.local:7848"
+	tests := []struct {
+		name  string
+		model IHealthModel
+		node  nacosClient.ServerNode
+		want  map[string]string
+	}{
+		{"v1", healthModelV1{}, serverNode(map[string]string{nacosClient.RAFT_GROUP_NAMING_PERSISTENT: leader}),
+			map[string]string{nacosClient.RAFT_GROUP_NAMING_PERSISTENT: leader}},
+		{"v1 no leader", healthModelV1{}, serverNode(nil),
+			map[string]string{nacosClient.RAFT_GROUP_NAMING_PERSISTENT: ""}},
+		{"v2 missing groups", healthModelV2{}, serverNode(map[string]string{nacosClient.RAFT_GROUP_NAMING_PERSISTENT_V2: leader}),
+			map[string]string{
+				nacosClient.RAFT_GROUP_NAMING_PERSISTENT_V2: leader,
+				nacosClient.RAFT_GROUP_INSTANCE_METADATA:    "",
+				nacosClient.RAFT_GROUP_SERVICE_METADATA:     "",
+			}},
+		{"extra group", healthModelV1{}, serverNode(map[string]string{nacosClient.RAFT_GROUP_NAMING_PERSISTENT: leader, "nacos_config": leader}),
+			map[string]string{nacosClient.RAFT_GROUP_NAMING_PERSISTENT: leader, "nacos_config": leader}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaders := raftLeaders(tt.model, tt.node)
+			if leadersKey(leaders) != leadersKey(tt.want) {
+				t.Errorf("raftLeaders() = %v, want %v", leaders, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeadersKey(t *testing.T) {
+	a := leadersKey(map[string]string{"b": "nacos-1", "a": "nacos-0"})
+	b := leadersKey(map[string]string{"a": "nacos-0", "b": "nacos-1"})
+	if a != b {
+		t.Errorf("leadersKey depends on map order: %s != %s", a, b)
+	}
+	if c := leadersKey(map[string]string{"a": "nacos-0", "b": "nacos-2"}); c == a {
+		t.Errorf("leadersKey(%s) should differ from %s", c, a)
+	}
+}
+
+func TestMemberPodName(t *testing.T) {
+	pods := []v1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "nacos-2"}, Status: v1.PodStatus{PodIP: "10.0.0.3"}},
+	}
+	tests := map[string]string{
+		"nacos-0.nacos-headless.default.svc.cluster.local:8848": "nacos-0",
+		"nacos-1:7848":  "nacos-1",
+		"10.0.0.3:8848": "nacos-2",
+		"10.0.0.4:8848": "",
+		"":              "",
+	}
+	for address, want := range tests {
+		if name := memberPodName(address, pods); name != want {
+			t.Errorf("memberPodName(%s) = %s, want %s", address, name, want)
+		}
+	}
+}
@@ -1,17 +1,29 @@
 package operator
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	batchv1 "k8s.io/api/batch/v1"
+	"math/big"
 	"path/filepath"
-	"strconv"
+	"reflect"
+	"sort"
+	"strings"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 
 	myErrors "nacos.io/nacos-operator/pkg/errors"
 
@@ -20,55 +32,124 @@ import (
 	v1 "k8s.io/api/core/v1"
 	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
 	"nacos.io/nacos-operator/pkg/service/k8s"
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
 )
 
 const TYPE_STAND_ALONE = "standalone"
 const TYPE_CLUSTER = "cluster"
 const NACOS = "nacos"
-const NACOS_PORT = 8848
-const RAFT_PORT = 7848
+
+// raft端口和nacos 2.x的grpc端口，相对spec.port偏移固定值，grpc端口分别用于客户端和集群成员之间
+const RAFT_PORT_OFFSET = -1000
+const CLIENT_GRPC_PORT_OFFSET = 1000
+const SERVER_GRPC_PORT_OFFSET = 1001
+
+// 默认probe访问的健康检查接口，相对spec.contextPath
+const READINESS_PATH = "/v1/console/health/readiness"
+const LIVENESS_PATH = "/v1/console/health/liveness"
+
+// 默认的startupProbe最多等待10分钟，覆盖raft回放日志较慢的情况
+const STARTUP_FAILURE_THRESHOLD = 60
+
+// operator生成的mysql账号secret中的key
+const MYSQL_SECRET_USER_KEY = "user"
+const MYSQL_SECRET_PASSWORD_KEY = "password"
+
+// 未指定用户时默认的mysql用户
+const MYSQL_DEFAULT_USER = "root"
+
+// 随机生成的mysql密码长度
+const MYSQL_PASSWORD_LENGTH = 16
+
+// operator生成的鉴权secret中的key，token密钥和节点间的身份标识每个集群随机生成
+const AUTH_SECRET_TOKEN_KEY = "tokenSecretKey"
+const AUTH_SECRET_IDENTITY_KEY = "identityKey"
+const AUTH_SECRET_IDENTITY_VALUE = "identityValue"
+
+// 当前nacos中管理员的密码，由operator维护
+const AUTH_SECRET_ADMIN_PASSWORD = "adminPassword"
+
+// 鉴权secret上记录adminPassword已经生效，在此之前nacos中管理员仍是初始密码
+const ANNOTATION_ADMIN_PASSWORD_ROTATED = "nacos.io/admin-password-rotated"
+
+// spec.auth.credentialsSecretRef中的key
+const CREDENTIALS_USERNAME_KEY = "username"
+const CREDENTIALS_PASSWORD_KEY = "password"
+
+// nacos内置的管理员用户和初始密码
+const NACOS_ADMIN_USER = "nacos"
+const NACOS_DEFAULT_PASSWORD = "nacos"
+
+// pod模板上记录引用secret内容的hash，secret变化后触发滚动更新
+const ANNOTATION_SECRET_HASH = "nacos.io/secret-hash"
+
+// pod模板上记录自定义配置的hash，配置变化后触发滚动更新
+const ANNOTATION_CONFIG_HASH = "nacos.io/config-hash"
+
+// statefulset上记录期望spec的hash，用于判断是否需要更新
+const ANNOTATION_SPEC_HASH = "nacos.io/spec-hash"
+
+// statefulset上记录当前这一步滚动更新开始的时间，用于判断是否超时
+const ANNOTATION_ROLLING_STEP_TIME = "nacos.io/rolling-step-time"
 
 // 导入的sql文件名称
 const SQL_FILE_NAME = "nacos-mysql.sql"
 
-var initScrit = `array=(%s)
-succ = 0
+// 集群成员配置挂载目录，由operator维护的configmap提供
+const CLUSTER_CONF_DIR = "/home/nacos/cluster-conf"
+const CLUSTER_CONF_KEY = "cluster.conf"
 
-for element in ${array[@]} 
+// 等待cluster.conf中的成员域名可以解析后再启动，启动后持续把configmap中的cluster.conf同步到conf目录，
+// nacos监听conf/cluster.conf的变化刷新集群成员，扩缩容时不需要重启已有的节点
+var initScrit = `CONF=%[1]s/%[2]s
+for member in $(cat $CONF)
 do
-  while true
+  host=${member%%%%:*}
+  until ping $host -c 1 > /dev/stdout
   do
-    ping $element -c 1 > /dev/stdout
-    if [[ $? -eq 0 ]]; then
-      echo $element "all domain ready"
-      break
-    else
-      echo $element "wait for other domain ready"
-    fi
+    echo $host "wait for other domain ready"
     sleep 1
   done
 done
-sleep 1
+echo "init success"
 
-echo "init success"`
+export NACOS_SERVERS="$(cat $CONF | tr '\n' ' ')"
+mkdir -p conf
+cp $CONF conf/%[2]s
+(while true
+do
+  sleep 5
+  if [ "$(md5sum < $CONF)" != "$(md5sum < conf/%[2]s)" ]; then
+    cp $CONF conf/%[2]s
+    echo "cluster.conf updated"
+  fi
+done) &
+exec bin/docker-startup.sh`
 
 type IKindClient interface {
-	Ensure(nacos nacosgroupv1alpha1.Nacos)
-	EnsureStatefulset(nacos nacosgroupv1alpha1.Nacos)
-	EnsureConfigmap(nacos nacosgroupv1alpha1.Nacos)
+	EnsureStatefulset(nacos *nacosgroupv1alpha1.Nacos) error
+	EnsureConfigmap(nacos *nacosgroupv1alpha1.Nacos) error
 }
 
 type KindClient struct {
 	k8sService k8s.Services
 	logger     log.Logger
 	scheme     *runtime.Scheme
+	// 维护ServiceMonitor等非内置资源
+	client client.Client
+	// 所有实例共用，缓存登录的token
+	nacosClient *nacosClient.NacosClient
+	recorder    record.EventRecorder
 }
 
-func NewKindClient(logger log.Logger, k8sService k8s.Services, scheme *runtime.Scheme) *KindClient {
+func NewKindClient(logger log.Logger, k8sService k8s.Services, scheme *runtime.Scheme, client client.Client, nacos *nacosClient.NacosClient, recorder record.EventRecorder) *KindClient {
 	return &KindClient{
-		k8sService: k8sService,
-		logger:     logger,
-		scheme:     scheme,
+		k8sService:  k8sService,
+		logger:      logger,
+		scheme:      scheme,
+		client:      client,
+		nacosClient: nacos,
+		recorder:    recorder,
 	}
 }
 
@@ -108,89 +189,368 @@ func (e *KindClient) generateClientSvcName(nacos *nacosgroupv1alpha1.Nacos) stri
 	return fmt.Sprintf("%s-client", nacos.Name)
 }
 
-// CR格式验证
-func (e *KindClient) ValidationField(nacos *nacosgroupv1alpha1.Nacos) {
+// 访问nacos的service，单实例模式与cr同名，集群模式为client service
+func (e *KindClient) generateAccessSvcName(nacos *nacosgroupv1alpha1.Nacos) string {
+	if nacos.Spec.Type == TYPE_CLUSTER {
+		return e.generateClientSvcName(nacos)
+	}
+	return nacos.Name
+}
+
+// 集群内访问nacos的地址
+func (e *KindClient) generateAccessAddress(nacos *nacosgroupv1alpha1.Nacos) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", e.generateAccessSvcName(nacos), nacos.Namespace)
+}
+
+func (e *KindClient) generateClusterConfName(nacos *nacosgroupv1alpha1.Nacos) string {
+	return fmt.Sprintf("%s-cluster-conf", nacos.Name)
+}
+
+func (e *KindClient) generateAuthSecretName(nacos *nacosgroupv1alpha1.Nacos) string {
+	return fmt.Sprintf("%s-auth", nacos.Name)
+}
+
+func (e *KindClient) generateMysqlSecretName(nacos *nacosgroupv1alpha1.Nacos) string {
+	return fmt.Sprintf("%s-mysql-auth", nacos.Name)
+}
+
+// CR格式验证，未开启webhook时默认值也在这里补全
+func (e *KindClient) ValidationField(nacos *nacosgroupv1alpha1.Nacos) error {
+	nacos.Default()
+	if allErrs := nacos.ValidateSpec(); len(allErrs) > 0 {
+		return myErrors.New(myErrors.CODE_PARAMETER_ERROR, allErrs.ToAggregate().Error())
+	}
+	return nil
+}
 
-	if nacos.Spec.Type == "" {
-		nacos.Spec.Type = "standalone"
+func (e *KindClient) EnsureStatefulsetCluster(nacos *nacosgroupv1alpha1.Nacos) error {
+	ss, err := e.buildStatefulset(nacos)
+	if err != nil {
+		return err
+	}
+	ss = e.buildStatefulsetCluster(nacos, ss)
+	// 集群的副本数由ScaleClient逐个调整，这里只在创建时使用
+	if stored, err := e.k8sService.GetStatefulSet(nacos.Namespace, ss.Name); err == nil {
+		ss.Spec.Replicas = stored.Spec.Replicas
 	}
+	return e.ensureStatefulset(nacos, ss)
+}
 
-	// 默认设置内置数据库
-	if nacos.Spec.Database.TypeDatabase == "" {
-		nacos.Spec.Database.TypeDatabase = "embedded"
+// EnsureClusterConfigMap 创建保存集群成员的configmap，已存在时由ScaleClient维护
+func (e *KindClient) EnsureClusterConfigMap(nacos *nacosgroupv1alpha1.Nacos) error {
+	if _, err := e.k8sService.GetConfigMap(nacos.Namespace, e.generateClusterConfName(nacos)); err == nil {
+		return nil
 	}
-	// mysql设置默认值
-	if nacos.Spec.Database.TypeDatabase == "mysql" {
-		if nacos.Spec.Database.MysqlHost == "" {
-			nacos.Spec.Database.MysqlHost = "127.0.0.1"
+	cm, err := e.buildClusterConfigMap(nacos, memberReplicas(nacos))
+	if err != nil {
+		return err
+	}
+	if err := e.k8sService.CreateIfNotExistsConfigMap(nacos.Namespace, cm); err != nil {
+		return err
+	}
+	e.recordCreated(nacos, "ConfigMap", cm.Name)
+	return nil
+}
+
+func (e *KindClient) EnsureStatefulset(nacos *nacosgroupv1alpha1.Nacos) error {
+	ss, err := e.buildStatefulset(nacos)
+	if err != nil {
+		return err
+	}
+	return e.ensureStatefulset(nacos, ss)
+}
+
+// ensureStatefulset 通过pod模板的hash判断是否需要更新。spec变化时把partition设置为副本数，
+// 由RollingClient逐个降低partition完成滚动更新；只有副本数变化时保留当前的partition
+func (e *KindClient) ensureStatefulset(nacos *nacosgroupv1alpha1.Nacos, ss *appv1.StatefulSet) error {
+	stored, err := e.k8sService.GetStatefulSet(nacos.Namespace, ss.Name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			hash, err := e.specHash(ss)
+			if err != nil {
+				return err
+			}
+			ss.Annotations = e.MergeLabels(ss.Annotations, map[string]string{ANNOTATION_SPEC_HASH: hash})
+			ss.Spec.UpdateStrategy = rollingUpdateStrategy(0)
+			if err := e.k8sService.CreateStatefulSet(nacos.Namespace, ss); err != nil {
+				return err
+			}
+			e.recordCreated(nacos, "StatefulSet", ss.Name)
+			return nil
 		}
-		if nacos.Spec.Database.MysqlUser == "" {
-			nacos.Spec.Database.MysqlUser = "root"
+		return err
+	}
+
+	// volumeClaimTemplates不能修改，变化时只记录condition，不更新statefulset
+	if drift := volumeClaimTemplatesDrift(stored.Spec.VolumeClaimTemplates, ss.Spec.VolumeClaimTemplates); drift != "" {
+		if condition := nacos.Status.FindCondition(nacosgroupv1alpha1.ConditionSpecDrifted); condition == nil || condition.Status != metav1.ConditionTrue {
+			e.recorder.Eventf(nacos, v1.EventTypeWarning, EVENT_REASON_SPEC_DRIFTED, "statefulset %s is not updated: %s", ss.Name, drift)
 		}
-		if nacos.Spec.Database.MysqlDb == "" {
-			nacos.Spec.Database.MysqlDb = "nacos"
+		setCondition(nacos, nacosgroupv1alpha1.ConditionSpecDrifted, true, "VolumeClaimTemplatesChanged", drift)
+		return nil
+	}
+	setCondition(nacos, nacosgroupv1alpha1.ConditionSpecDrifted, false, "InSync", "")
+	keepImmutableFields(stored, ss)
+
+	hash, err := e.specHash(ss)
+	if err != nil {
+		return err
+	}
+	ss.Annotations = e.MergeLabels(ss.Annotations, map[string]string{ANNOTATION_SPEC_HASH: hash})
+	specChanged := stored.Annotations[ANNOTATION_SPEC_HASH] != hash
+	if !specChanged && *stored.Spec.Replicas == *ss.Spec.Replicas {
+		return nil
+	}
+	partition := *ss.Spec.Replicas
+	if !specChanged {
+		// 只有副本数变化时继续当前的滚动，保留这一步的开始时间
+		partition = statefulsetPartition(stored)
+		if stepTime, ok := stored.Annotations[ANNOTATION_ROLLING_STEP_TIME]; ok {
+			ss.Annotations[ANNOTATION_ROLLING_STEP_TIME] = stepTime
 		}
-		if nacos.Spec.Database.MysqlPassword == "" {
-			nacos.Spec.Database.MysqlPassword = "123456"
+	}
+	ss.Spec.UpdateStrategy = rollingUpdateStrategy(partition)
+	ss.ResourceVersion = stored.ResourceVersion
+	if err := e.k8sService.UpdateStatefulSet(nacos.Namespace, ss); err != nil {
+		return err
+	}
+	e.recordUpdated(nacos, "StatefulSet", ss.Name)
+	return nil
+}
+
+// keepImmutableFields 使用已有statefulset中不能修改的字段，pod的label始终包含已有的selector
+func keepImmutableFields(stored *appv1.StatefulSet, ss *appv1.StatefulSet) {
+	ss.Spec.Selector = stored.Spec.Selector
+	ss.Spec.ServiceName = stored.Spec.ServiceName
+	ss.Spec.PodManagementPolicy = stored.Spec.PodManagementPolicy
+	ss.Spec.VolumeClaimTemplates = stored.Spec.VolumeClaimTemplates
+	if stored.Spec.Selector != nil {
+		labels := map[string]string{}
+		for k, v := range ss.Spec.Template.Labels {
+			labels[k] = v
 		}
-		if nacos.Spec.Database.MysqlPort == "" {
-			nacos.Spec.Database.MysqlPort = "3306"
+		for k, v := range stored.Spec.Selector.MatchLabels {
+			labels[k] = v
 		}
+		ss.Spec.Template.Labels = labels
 	}
 }
 
-func (e *KindClient) EnsureStatefulsetCluster(nacos *nacosgroupv1alpha1.Nacos) {
-	ss := e.buildStatefulset(nacos)
-	ss = e.buildStatefulsetCluster(nacos, ss)
-	myErrors.EnsureNormal(e.k8sService.CreateOrUpdateStatefulSet(nacos.Namespace, ss))
+// volumeClaimTemplatesDrift 比较存储相关的字段，返回变化的描述，没有变化时返回空。
+// label和apiserver填充的默认值不参与比较
+func volumeClaimTemplatesDrift(stored []v1.PersistentVolumeClaim, desired []v1.PersistentVolumeClaim) string {
+	if len(stored) != len(desired) {
+		return fmt.Sprintf("spec.volume.enabled changed, volumeClaimTemplates %d -> %d", len(stored), len(desired))
+	}
+	for i := range desired {
+		old, cur := stored[i].Spec, desired[i].Spec
+		if stored[i].Name != desired[i].Name {
+			return fmt.Sprintf("volumeClaimTemplate %s renamed to %s", stored[i].Name, desired[i].Name)
+		}
+		if stringValue(old.StorageClassName) != stringValue(cur.StorageClassName) {
+			return fmt.Sprintf("storageClass of %s changed from %q to %q", desired[i].Name, stringValue(old.StorageClassName), stringValue(cur.StorageClassName))
+		}
+		if !reflect.DeepEqual(old.AccessModes, cur.AccessModes) {
+			return fmt.Sprintf("accessModes of %s changed", desired[i].Name)
+		}
+		oldSize, curSize := old.Resources.Requests[v1.ResourceStorage], cur.Resources.Requests[v1.ResourceStorage]
+		if oldSize.Cmp(curSize) != 0 {
+			return fmt.Sprintf("storage request of %s changed from %s to %s", desired[i].Name, oldSize.String(), curSize.String())
+		}
+	}
+	return ""
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
-func (e *KindClient) EnsureStatefulset(nacos *nacosgroupv1alpha1.Nacos) {
-	ss := e.buildStatefulset(nacos)
-	myErrors.EnsureNormal(e.k8sService.CreateOrUpdateStatefulSet(nacos.Namespace, ss))
+// specHash 计算pod模板的hash，副本数和更新策略由operator单独维护，不能修改的字段也不参与计算
+func (e *KindClient) specHash(ss *appv1.StatefulSet) (string, error) {
+	data, err := json.Marshal(ss.Spec.Template)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func rollingUpdateStrategy(partition int32) appv1.StatefulSetUpdateStrategy {
+	return appv1.StatefulSetUpdateStrategy{
+		Type: appv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appv1.RollingUpdateStatefulSetStrategy{
+			Partition: &partition,
+		},
+	}
 }
 
-func (e *KindClient) EnsureService(nacos *nacosgroupv1alpha1.Nacos) {
-	ss := e.buildService(nacos)
-	myErrors.EnsureNormal(e.k8sService.CreateIfNotExistsService(nacos.Namespace, ss))
+func statefulsetPartition(ss *appv1.StatefulSet) int32 {
+	if ss.Spec.UpdateStrategy.RollingUpdate != nil && ss.Spec.UpdateStrategy.RollingUpdate.Partition != nil {
+		return *ss.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+	return 0
 }
 
-func (e *KindClient) EnsureServiceCluster(nacos *nacosgroupv1alpha1.Nacos) {
-	ss := e.buildService(nacos)
-	myErrors.EnsureNormal(e.k8sService.CreateOrUpdateService(nacos.Namespace, ss))
+func (e *KindClient) EnsureService(nacos *nacosgroupv1alpha1.Nacos) error {
+	ss, err := e.buildService(nacos)
+	if err != nil {
+		return err
+	}
+	// 升级到2.x后需要补充grpc端口
+	return e.ensureService(nacos, ss)
 }
 
-func (e *KindClient) EnsureClientService(nacos *nacosgroupv1alpha1.Nacos) {
-	ss := e.buildClientService(nacos)
-	myErrors.EnsureNormal(e.k8sService.CreateIfNotExistsService(nacos.Namespace, ss))
+func (e *KindClient) EnsureServiceCluster(nacos *nacosgroupv1alpha1.Nacos) error {
+	ss, err := e.buildService(nacos)
+	if err != nil {
+		return err
+	}
+	return e.ensureService(nacos, ss)
 }
 
-func (e *KindClient) EnsureHeadlessServiceCluster(nacos *nacosgroupv1alpha1.Nacos) {
-	ss := e.buildService(nacos)
+func (e *KindClient) EnsureClientService(nacos *nacosgroupv1alpha1.Nacos) error {
+	ss, err := e.buildClientService(nacos)
+	if err != nil {
+		return err
+	}
+	// 升级到2.x后需要补充grpc端口
+	return e.ensureService(nacos, ss)
+}
+
+func (e *KindClient) EnsureHeadlessServiceCluster(nacos *nacosgroupv1alpha1.Nacos) error {
+	ss, err := e.buildService(nacos)
+	if err != nil {
+		return err
+	}
 	ss = e.buildHeadlessServiceCluster(ss, nacos)
-	myErrors.EnsureNormal(e.k8sService.CreateOrUpdateService(nacos.Namespace, ss))
+	return e.ensureService(nacos, ss)
+}
+
+// ensureService 创建或更新service，只有创建时记录事件
+func (e *KindClient) ensureService(nacos *nacosgroupv1alpha1.Nacos, svc *v1.Service) error {
+	_, err := e.k8sService.GetService(nacos.Namespace, svc.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	created := errors.IsNotFound(err)
+	if err := e.k8sService.CreateOrUpdateService(nacos.Namespace, svc); err != nil {
+		return err
+	}
+	if created {
+		e.recordCreated(nacos, "Service", svc.Name)
+	}
+	return nil
+}
+
+// ensureConfigMap 创建或更新configmap，data变化时先更新，保证滚动重启的pod读到新的配置
+func (e *KindClient) ensureConfigMap(nacos *nacosgroupv1alpha1.Nacos, cm *v1.ConfigMap) error {
+	stored, err := e.k8sService.GetConfigMap(nacos.Namespace, cm.Name)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if err := e.k8sService.CreateConfigMap(nacos.Namespace, cm); err != nil {
+			return err
+		}
+		e.recordCreated(nacos, "ConfigMap", cm.Name)
+		return nil
+	}
+	if reflect.DeepEqual(stored.Data, cm.Data) {
+		return nil
+	}
+	if err := e.k8sService.CreateOrUpdateConfigMap(nacos.Namespace, cm); err != nil {
+		return err
+	}
+	e.recordUpdated(nacos, "ConfigMap", cm.Name)
+	return nil
 }
 
-func (e *KindClient) EnsureConfigmap(nacos *nacosgroupv1alpha1.Nacos) {
+func (e *KindClient) EnsureConfigmap(nacos *nacosgroupv1alpha1.Nacos) error {
 	if nacos.Spec.Config != "" {
-		cm := e.buildConfigMap(nacos)
-		myErrors.EnsureNormal(e.k8sService.CreateIfNotExistsConfigMap(nacos.Namespace, cm))
+		cm, err := e.buildConfigMap(nacos)
+		if err != nil {
+			return err
+		}
+		return e.ensureConfigMap(nacos, cm)
+	}
+	return nil
+}
+
+func (e *KindClient) EnsureMysqlConfigMap(nacos *nacosgroupv1alpha1.Nacos) error {
+	cm, err := e.buildMysqlConfigMap(nacos)
+	if err != nil {
+		return err
 	}
+	return e.ensureConfigMap(nacos, cm)
 }
 
-func (e *KindClient) EnsureMysqlConfigMap(nacos *nacosgroupv1alpha1.Nacos) {
-	cm := e.buildMysqlConfigMap(nacos)
-	myErrors.EnsureNormal(e.k8sService.CreateIfNotExistsConfigMap(nacos.Namespace, cm))
+// EnsureMysqlSecret 未指定secret时生成mysql账号secret，已存在时不覆盖
+func (e *KindClient) EnsureMysqlSecret(nacos *nacosgroupv1alpha1.Nacos) error {
+	if nacos.Spec.Database.UserSecretRef != nil && nacos.Spec.Database.PasswordSecretRef != nil {
+		return nil
+	}
+	if _, err := e.k8sService.GetSecret(nacos.Namespace, e.generateMysqlSecretName(nacos)); err == nil {
+		return nil
+	}
+	secret, err := e.buildMysqlSecret(nacos)
+	if err != nil {
+		return err
+	}
+	if err := e.k8sService.CreateIfNotExistsSecret(nacos.Namespace, secret); err != nil {
+		return err
+	}
+	e.recordCreated(nacos, "Secret", secret.Name)
+	return nil
+}
+
+// EnsureAuthSecret 生成token密钥、节点身份标识和管理员密码，已存在时不覆盖
+func (e *KindClient) EnsureAuthSecret(nacos *nacosgroupv1alpha1.Nacos) error {
+	if _, err := e.k8sService.GetSecret(nacos.Namespace, e.generateAuthSecretName(nacos)); err == nil {
+		return nil
+	}
+	secret, err := e.buildAuthSecret(nacos)
+	if err != nil {
+		return err
+	}
+	if err := e.k8sService.CreateIfNotExistsSecret(nacos.Namespace, secret); err != nil {
+		return err
+	}
+	e.recordCreated(nacos, "Secret", secret.Name)
+	return nil
 }
 
-func (e *KindClient) EnsureJob(nacos *nacosgroupv1alpha1.Nacos) {
+func (e *KindClient) EnsureJob(nacos *nacosgroupv1alpha1.Nacos) error {
 	// 使用job执行SQL脚本的逻辑
-	job := e.buildJob(nacos)
-	myErrors.EnsureNormal(e.k8sService.CreateIfNotExistsJob(nacos.Namespace, job))
+	job, err := e.buildJob(nacos)
+	if err != nil {
+		return err
+	}
+	stored, err := e.k8sService.GetJob(nacos.Namespace, job.Name)
+	if err == nil {
+		// mysql账号变化后删除job，job删除后重新创建，sql中的建表语句可以重复执行
+		if stored.DeletionTimestamp == nil && stored.Annotations[ANNOTATION_SECRET_HASH] != job.Annotations[ANNOTATION_SECRET_HASH] {
+			if err := e.k8sService.DeleteJob(nacos.Namespace, job.Name); err != nil {
+				return err
+			}
+			e.recordUpdated(nacos, "Job", job.Name)
+		}
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	if err := e.k8sService.CreateJob(nacos.Namespace, job); err != nil {
+		return err
+	}
+	e.recordCreated(nacos, "Job", job.Name)
+	return nil
 }
 
 // buildSqlConfigMap 创建用于保存待导入的sql的configmap
-func (e *KindClient) buildMysqlConfigMap(nacos *nacosgroupv1alpha1.Nacos) *v1.ConfigMap {
+func (e *KindClient) buildMysqlConfigMap(nacos *nacosgroupv1alpha1.Nacos) (*v1.ConfigMap, error) {
 	labels := e.generateLabels(nacos.Name, NACOS)
 	labels = e.MergeLabels(nacos.Labels, labels)
 
@@ -206,21 +566,213 @@ func (e *KindClient) buildMysqlConfigMap(nacos *nacosgroupv1alpha1.Nacos) *v1.Co
 			"SQL_SCRIPT": readSql(SQL_FILE_NAME),
 		},
 	}
-	myErrors.EnsureNormal(controllerutil.SetControllerReference(nacos, cm, e.scheme))
-	return cm
+	if err := controllerutil.SetControllerReference(nacos, cm, e.scheme); err != nil {
+		return nil, err
+	}
+	return cm, nil
 }
 
-func (e *KindClient) buildJob(nacos *nacosgroupv1alpha1.Nacos) *batchv1.Job {
+// buildMysqlSecret 兼容旧的mysqlUser/mysqlPassword字段，未配置时随机生成密码
+func (e *KindClient) buildMysqlSecret(nacos *nacosgroupv1alpha1.Nacos) (*v1.Secret, error) {
 	labels := e.generateLabels(nacos.Name, NACOS)
 	labels = e.MergeLabels(nacos.Labels, labels)
 
-	// 创建Job用于向数据库中导入sql
-	job := &batchv1.Job{
+	user := nacos.Spec.Database.MysqlUser
+	if user == "" {
+		user = MYSQL_DEFAULT_USER
+	}
+	password := nacos.Spec.Database.MysqlPassword
+	if password == "" {
+		var err error
+		if password, err = generatePassword(MYSQL_PASSWORD_LENGTH); err != nil {
+			return nil, err
+		}
+	}
+
+	secret := &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      nacos.Name + "-mysql-sql-init",
+			Name:      e.generateMysqlSecretName(nacos),
+			Namespace: nacos.Namespace,
+			Labels:    labels,
+		},
+		Type: v1.SecretTypeOpaque,
+		StringData: map[string]string{
+			MYSQL_SECRET_USER_KEY:     user,
+			MYSQL_SECRET_PASSWORD_KEY: password,
+		},
+	}
+	if err := controllerutil.SetControllerReference(nacos, secret, e.scheme); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// buildAuthSecret nacos要求token密钥为base64编码，解码后至少32字节
+func (e *KindClient) buildAuthSecret(nacos *nacosgroupv1alpha1.Nacos) (*v1.Secret, error) {
+	labels := e.generateLabels(nacos.Name, NACOS)
+	labels = e.MergeLabels(nacos.Labels, labels)
+
+	key := make([]byte, 48)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	data := map[string]string{
+		AUTH_SECRET_TOKEN_KEY: base64.StdEncoding.EncodeToString(key),
+	}
+	for _, k := range []string{AUTH_SECRET_IDENTITY_KEY, AUTH_SECRET_IDENTITY_VALUE, AUTH_SECRET_ADMIN_PASSWORD} {
+		value, err := generatePassword(MYSQL_PASSWORD_LENGTH)
+		if err != nil {
+			return nil, err
+		}
+		data[k] = value
+	}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      e.generateAuthSecretName(nacos),
 			Namespace: nacos.Namespace,
 			Labels:    labels,
 		},
+		Type:       v1.SecretTypeOpaque,
+		StringData: data,
+	}
+	if err := controllerutil.SetControllerReference(nacos, secret, e.scheme); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (e *KindClient) authSelector(nacos *nacosgroupv1alpha1.Nacos, key string) *v1.SecretKeySelector {
+	return &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: e.generateAuthSecretName(nacos)},
+		Key:                  key,
+	}
+}
+
+// AuthCredentials operator访问nacos使用的账号，优先使用credentialsSecretRef，未开启鉴权时返回nil
+func (e *KindClient) AuthCredentials(nacos *nacosgroupv1alpha1.Nacos) (*nacosClient.Credentials, error) {
+	if ref := nacos.Spec.Auth.CredentialsSecretRef; ref != nil {
+		secret, err := e.k8sService.GetSecret(nacos.Namespace, ref.Name)
+		if err != nil {
+			return nil, myErrors.New(myErrors.CODE_PARAMETER_ERROR, "get secret %s failed: %s", ref.Name, err.Error())
+		}
+		return &nacosClient.Credentials{
+			Username: string(secret.Data[CREDENTIALS_USERNAME_KEY]),
+			Password: string(secret.Data[CREDENTIALS_PASSWORD_KEY]),
+		}, nil
+	}
+	if !nacos.Spec.Auth.Enabled {
+		return nil, nil
+	}
+	secret, err := e.k8sService.GetSecret(nacos.Namespace, e.generateAuthSecretName(nacos))
+	if err != nil {
+		return nil, err
+	}
+	// MakeAuth修改密码之前使用初始密码
+	password := NACOS_DEFAULT_PASSWORD
+	if adminPasswordRotated(secret) {
+		password = string(secret.Data[AUTH_SECRET_ADMIN_PASSWORD])
+	}
+	return &nacosClient.Credentials{
+		Username: NACOS_ADMIN_USER,
+		Password: password,
+	}, nil
+}
+
+// adminPasswordRotated 鉴权secret中的adminPassword是否已经在nacos中生效
+func adminPasswordRotated(secret *v1.Secret) bool {
+	return secret.Annotations[ANNOTATION_ADMIN_PASSWORD_ROTATED] == "true"
+}
+
+// nacosClientFor 使用实例对应账号的client
+func (e *KindClient) nacosClientFor(nacos *nacosgroupv1alpha1.Nacos) (*nacosClient.NacosClient, error) {
+	credentials, err := e.AuthCredentials(nacos)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := e.baseNacosClient(nacos)
+	if err != nil {
+		return nil, err
+	}
+	return cli.WithCredentials(credentials), nil
+}
+
+// mysql用户名的来源，优先使用cr中指定的secret
+func (e *KindClient) mysqlUserSelector(nacos *nacosgroupv1alpha1.Nacos) *v1.SecretKeySelector {
+	if nacos.Spec.Database.UserSecretRef != nil {
+		return nacos.Spec.Database.UserSecretRef
+	}
+	return &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: e.generateMysqlSecretName(nacos)},
+		Key:                  MYSQL_SECRET_USER_KEY,
+	}
+}
+
+// mysql密码的来源，优先使用cr中指定的secret
+func (e *KindClient) mysqlPasswordSelector(nacos *nacosgroupv1alpha1.Nacos) *v1.SecretKeySelector {
+	if nacos.Spec.Database.PasswordSecretRef != nil {
+		return nacos.Spec.Database.PasswordSecretRef
+	}
+	return &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: e.generateMysqlSecretName(nacos)},
+		Key:                  MYSQL_SECRET_PASSWORD_KEY,
+	}
+}
+
+// secretHash 计算引用的secret内容的hash，secret不存在或缺少key时返回参数错误
+func (e *KindClient) secretHash(nacos *nacosgroupv1alpha1.Nacos, selectors ...*v1.SecretKeySelector) (string, error) {
+	values := []string{}
+	for _, selector := range selectors {
+		secret, err := e.k8sService.GetSecret(nacos.Namespace, selector.Name)
+		if err != nil {
+			return "", myErrors.New(myErrors.CODE_PARAMETER_ERROR, "get secret %s failed: %s", selector.Name, err.Error())
+		}
+		value, ok := secret.Data[selector.Key]
+		if !ok {
+			return "", myErrors.New(myErrors.CODE_PARAMETER_ERROR, "secret %s has no key %s", selector.Name, selector.Key)
+		}
+		values = append(values, fmt.Sprintf("%s/%s=%s", selector.Name, selector.Key, value))
+	}
+	sort.Strings(values)
+	sum := sha256.New()
+	for _, value := range values {
+		sum.Write([]byte(value))
+		sum.Write([]byte{0})
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func generatePassword(length int) (string, error) {
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	res := make([]byte, length)
+	for i := range res {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			return "", err
+		}
+		res[i] = letters[n.Int64()]
+	}
+	return string(res), nil
+}
+
+func (e *KindClient) buildJob(nacos *nacosgroupv1alpha1.Nacos) (*batchv1.Job, error) {
+	labels := e.generateLabels(nacos.Name, NACOS)
+	labels = e.MergeLabels(nacos.Labels, labels)
+
+	// 记录mysql账号的hash，账号变化后重新执行
+	hash, err := e.secretHash(nacos, e.mysqlUserSelector(nacos), e.mysqlPasswordSelector(nacos))
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建Job用于向数据库中导入sql
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        nacos.Name + "-mysql-sql-init",
+			Namespace:   nacos.Namespace,
+			Labels:      labels,
+			Annotations: map[string]string{ANNOTATION_SECRET_HASH: hash},
+		},
 		Spec: batchv1.JobSpec{
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
@@ -261,12 +813,16 @@ func (e *KindClient) buildJob(nacos *nacosgroupv1alpha1.Nacos) *batchv1.Job {
 									Value: nacos.Spec.Database.MysqlPort,
 								},
 								{
-									Name:  "MYSQL_USER",
-									Value: nacos.Spec.Database.MysqlUser,
+									Name: "MYSQL_USER",
+									ValueFrom: &v1.EnvVarSource{
+										SecretKeyRef: e.mysqlUserSelector(nacos),
+									},
 								},
 								{
-									Name:  "MYSQL_PASS",
-									Value: nacos.Spec.Database.MysqlPassword,
+									Name: "MYSQL_PASS",
+									ValueFrom: &v1.EnvVarSource{
+										SecretKeyRef: e.mysqlPasswordSelector(nacos),
+									},
 								},
 							},
 							// 判断数据库是否存在，不存在则创建
@@ -295,12 +851,16 @@ func (e *KindClient) buildJob(nacos *nacosgroupv1alpha1.Nacos) *batchv1.Job {
 									Value: nacos.Spec.Database.MysqlPort,
 								},
 								{
-									Name:  "MYSQL_USER",
-									Value: nacos.Spec.Database.MysqlUser,
+									Name: "MYSQL_USER",
+									ValueFrom: &v1.EnvVarSource{
+										SecretKeyRef: e.mysqlUserSelector(nacos),
+									},
 								},
 								{
-									Name:  "MYSQL_PASS",
-									Value: nacos.Spec.Database.MysqlPassword,
+									Name: "MYSQL_PASS",
+									ValueFrom: &v1.EnvVarSource{
+										SecretKeyRef: e.mysqlPasswordSelector(nacos),
+									},
 								},
 								{
 									Name: "SQL_SCRIPT",
@@ -329,8 +889,77 @@ func (e *KindClient) buildJob(nacos *nacosgroupv1alpha1.Nacos) *batchv1.Job {
 		},
 	}
 
-	myErrors.EnsureNormal(controllerutil.SetControllerReference(nacos, job, e.scheme))
-	return job
+	if err := controllerutil.SetControllerReference(nacos, job, e.scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// buildMysqlCleanupJob 删除nacos在mysql中创建的表，deletionPolicy为Delete时使用
+func (e *KindClient) buildMysqlCleanupJob(nacos *nacosgroupv1alpha1.Nacos, tables []string) (*batchv1.Job, error) {
+	labels := e.generateLabels(nacos.Name, NACOS)
+	backoffLimit := int32(BACKUP_JOB_BACKOFF_LIMIT)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nacos.Name + "-mysql-cleanup",
+			Namespace: nacos.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Name:  "mysql-cleanup",
+							Image: nacos.Spec.MysqlInitImage,
+							Env: []v1.EnvVar{
+								{
+									Name:  "MYSQL_HOST",
+									Value: nacos.Spec.Database.MysqlHost,
+								},
+								{
+									Name:  "MYSQL_DB",
+									Value: nacos.Spec.Database.MysqlDb,
+								},
+								{
+									Name:  "MYSQL_PORT",
+									Value: nacos.Spec.Database.MysqlPort,
+								},
+								{
+									Name: "MYSQL_USER",
+									ValueFrom: &v1.EnvVarSource{
+										SecretKeyRef: e.mysqlUserSelector(nacos),
+									},
+								},
+								{
+									Name: "MYSQL_PASS",
+									ValueFrom: &v1.EnvVarSource{
+										SecretKeyRef: e.mysqlPasswordSelector(nacos),
+									},
+								},
+								{
+									Name:  "SQL_SCRIPT",
+									Value: fmt.Sprintf("DROP TABLE IF EXISTS `%s`;", strings.Join(tables, "`, `")),
+								},
+							},
+							Command: []string{
+								"/bin/sh",
+								"-c",
+								"mysql -u\"${MYSQL_USER}\" -p\"${MYSQL_PASS}\" -h\"${MYSQL_HOST}\" -P\"${MYSQL_PORT}\" -D\"${MYSQL_DB}\" -e\"${SQL_SCRIPT}\";",
+							},
+						},
+					},
+					RestartPolicy: "Never",
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(nacos, job, e.scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
 }
 
 func readSql(sqlFileName string) string {
@@ -345,7 +974,7 @@ func readSql(sqlFileName string) string {
 	return string(bytes)
 }
 
-func (e *KindClient) buildService(nacos *nacosgroupv1alpha1.Nacos) *v1.Service {
+func (e *KindClient) buildService(nacos *nacosgroupv1alpha1.Nacos) (*v1.Service, error) {
 	labels := e.generateLabels(nacos.Name, NACOS)
 	labels = e.MergeLabels(nacos.Labels, labels)
 
@@ -362,24 +991,28 @@ func (e *KindClient) buildService(nacos *nacosgroupv1alpha1.Nacos) *v1.Service {
 			PublishNotReadyAddresses: true,
 			Ports: []v1.ServicePort{
 				{
-					Name:     "client",
-					Port:     NACOS_PORT,
-					Protocol: "TCP",
+					Name:        "client",
+					Port:        nacos.ServerPort(),
+					Protocol:    "TCP",
+					AppProtocol: clientAppProtocol(nacos),
 				},
 				{
 					Name:     "rpc",
-					Port:     RAFT_PORT,
+					Port:     nacos.ServerPort() + RAFT_PORT_OFFSET,
 					Protocol: "TCP",
 				},
 			},
 			Selector: labels,
 		},
 	}
-	myErrors.EnsureNormal(controllerutil.SetControllerReference(nacos, svc, e.scheme))
-	return svc
+	svc.Spec.Ports = append(svc.Spec.Ports, e.grpcServicePorts(nacos, true)...)
+	if err := controllerutil.SetControllerReference(nacos, svc, e.scheme); err != nil {
+		return nil, err
+	}
+	return svc, nil
 }
 
-func (e *KindClient) buildClientService(nacos *nacosgroupv1alpha1.Nacos) *v1.Service {
+func (e *KindClient) buildClientService(nacos *nacosgroupv1alpha1.Nacos) (*v1.Service, error) {
 	labels := e.generateLabels(nacos.Name, NACOS)
 	labels = e.MergeLabels(nacos.Labels, labels)
 
@@ -396,19 +1029,23 @@ func (e *KindClient) buildClientService(nacos *nacosgroupv1alpha1.Nacos) *v1.Ser
 			PublishNotReadyAddresses: true,
 			Ports: []v1.ServicePort{
 				{
-					Name:     "client",
-					Port:     NACOS_PORT,
-					Protocol: "TCP",
+					Name:        "client",
+					Port:        nacos.ServerPort(),
+					Protocol:    "TCP",
+					AppProtocol: clientAppProtocol(nacos),
 				},
 			},
 			Selector: labels,
 		},
 	}
-	myErrors.EnsureNormal(controllerutil.SetControllerReference(nacos, svc, e.scheme))
-	return svc
+	svc.Spec.Ports = append(svc.Spec.Ports, e.grpcServicePorts(nacos, false)...)
+	if err := controllerutil.SetControllerReference(nacos, svc, e.scheme); err != nil {
+		return nil, err
+	}
+	return svc, nil
 }
 
-func (e *KindClient) buildStatefulset(nacos *nacosgroupv1alpha1.Nacos) *appv1.StatefulSet {
+func (e *KindClient) buildStatefulset(nacos *nacosgroupv1alpha1.Nacos) (*appv1.StatefulSet, error) {
 	// 生成label
 	labels := e.generateLabels(nacos.Name, NACOS)
 	// 合并cr中原有的label
@@ -443,13 +1080,58 @@ func (e *KindClient) buildStatefulset(nacos *nacosgroupv1alpha1.Nacos) *appv1.St
 		})
 
 		env = append(env, v1.EnvVar{
-			Name:  "MYSQL_SERVICE_USER",
-			Value: nacos.Spec.Database.MysqlUser,
+			Name: "MYSQL_SERVICE_USER",
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: e.mysqlUserSelector(nacos),
+			},
 		})
 
 		env = append(env, v1.EnvVar{
-			Name:  "MYSQL_SERVICE_PASSWORD",
-			Value: nacos.Spec.Database.MysqlPassword,
+			Name: "MYSQL_SERVICE_PASSWORD",
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: e.mysqlPasswordSelector(nacos),
+			},
+		})
+	}
+
+	// 鉴权配置，token密钥和身份标识来自operator生成的secret，用户在spec.env中配置时以用户为准
+	authEnv := []v1.EnvVar{
+		{Name: "NACOS_AUTH_ENABLE", Value: fmt.Sprintf("%t", nacos.Spec.Auth.Enabled)},
+		{Name: "NACOS_AUTH_TOKEN", ValueFrom: &v1.EnvVarSource{SecretKeyRef: e.authSelector(nacos, AUTH_SECRET_TOKEN_KEY)}},
+		{Name: "NACOS_AUTH_IDENTITY_KEY", ValueFrom: &v1.EnvVarSource{SecretKeyRef: e.authSelector(nacos, AUTH_SECRET_IDENTITY_KEY)}},
+		{Name: "NACOS_AUTH_IDENTITY_VALUE", ValueFrom: &v1.EnvVarSource{SecretKeyRef: e.authSelector(nacos, AUTH_SECRET_IDENTITY_VALUE)}},
+	}
+	if nacos.Spec.Auth.TokenExpireSeconds > 0 {
+		authEnv = append(authEnv, v1.EnvVar{Name: "NACOS_AUTH_TOKEN_EXPIRE_SECONDS", Value: fmt.Sprintf("%d", nacos.Spec.Auth.TokenExpireSeconds)})
+	}
+	for _, item := range authEnv {
+		if !containsEnv(nacos.Spec.Env, item.Name) {
+			env = append(env, item)
+		}
+	}
+
+	// 端口和访问路径与默认值不同时才设置，避免已有的集群滚动更新
+	endpointEnv := []v1.EnvVar{}
+	if nacos.ServerPort() != nacosgroupv1alpha1.DefaultPort {
+		endpointEnv = append(endpointEnv, v1.EnvVar{Name: "NACOS_APPLICATION_PORT", Value: fmt.Sprintf("%d", nacos.ServerPort())})
+	}
+	if nacos.ServerContextPath() != nacosgroupv1alpha1.DefaultContextPath {
+		endpointEnv = append(endpointEnv, v1.EnvVar{Name: "SERVER_SERVLET_CONTEXTPATH", Value: nacos.ServerContextPath()})
+	}
+	for _, item := range endpointEnv {
+		if !containsEnv(nacos.Spec.Env, item.Name) {
+			env = append(env, item)
+		}
+	}
+
+	// 开启tls时使用证书中的keystore
+	env = append(env, e.tlsEnv(nacos)...)
+
+	// 开启监控时暴露prometheus endpoint，用户在spec.env中配置时以用户为准
+	if nacos.Spec.Monitoring.Enabled && !containsEnv(nacos.Spec.Env, METRICS_EXPOSURE_ENV) {
+		env = append(env, v1.EnvVar{
+			Name:  METRICS_EXPOSURE_ENV,
+			Value: METRICS_EXPOSURE,
 		})
 	}
 
@@ -459,11 +1141,27 @@ func (e *KindClient) buildStatefulset(nacos *nacosgroupv1alpha1.Nacos) *appv1.St
 			Name:  "MODE",
 			Value: "standalone",
 		})
-	} else {
-		env = append(env, v1.EnvVar{
-			Name:  "NACOS_REPLICAS",
-			Value: strconv.Itoa(int(*nacos.Spec.Replicas)),
-		})
+	}
+
+	// 引用的secret变化后滚动更新pod
+	podAnnotations := map[string]string{}
+	selectors := []*v1.SecretKeySelector{
+		e.authSelector(nacos, AUTH_SECRET_TOKEN_KEY),
+		e.authSelector(nacos, AUTH_SECRET_IDENTITY_KEY),
+		e.authSelector(nacos, AUTH_SECRET_IDENTITY_VALUE),
+	}
+	if nacos.Spec.Database.TypeDatabase == "mysql" {
+		selectors = append(selectors, e.mysqlUserSelector(nacos), e.mysqlPasswordSelector(nacos))
+	}
+	selectors = append(selectors, e.tlsSelectors(nacos)...)
+	hash, err := e.secretHash(nacos, selectors...)
+	if err != nil {
+		return nil, err
+	}
+	podAnnotations[ANNOTATION_SECRET_HASH] = hash
+	if nacos.Spec.Config != "" {
+		sum := sha256.Sum256([]byte(nacos.Spec.Config))
+		podAnnotations[ANNOTATION_CONFIG_HASH] = hex.EncodeToString(sum[:])
 	}
 
 	var ss = &appv1.StatefulSet{
@@ -471,15 +1169,17 @@ func (e *KindClient) buildStatefulset(nacos *nacosgroupv1alpha1.Nacos) *appv1.St
 			Name:        e.generateName(nacos),
 			Namespace:   nacos.Namespace,
 			Labels:      labels,
-			Annotations: nacos.Annotations,
+			Annotations: e.MergeLabels(nacos.Annotations),
 		},
 		Spec: appv1.StatefulSetSpec{
 			PodManagementPolicy: "Parallel",
 			Replicas:            nacos.Spec.Replicas,
-			Selector:            &metav1.LabelSelector{MatchLabels: labels},
+			// selector不能修改，只使用固定的label，cr中的label只加到pod上
+			Selector: &metav1.LabelSelector{MatchLabels: e.generateLabels(nacos.Name, NACOS)},
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+					Labels:      labels,
+					Annotations: podAnnotations,
 				},
 				Spec: v1.PodSpec{
 					Volumes:      []v1.Volume{},
@@ -493,12 +1193,12 @@ func (e *KindClient) buildStatefulset(nacos *nacosgroupv1alpha1.Nacos) *appv1.St
 							Ports: []v1.ContainerPort{
 								{
 									Name:          "client",
-									ContainerPort: NACOS_PORT,
+									ContainerPort: nacos.ServerPort(),
 									Protocol:      "TCP",
 								},
 								{
 									Name:          "rpc",
-									ContainerPort: RAFT_PORT,
+									ContainerPort: nacos.ServerPort() + RAFT_PORT_OFFSET,
 									Protocol:      "TCP",
 								},
 							},
@@ -526,40 +1226,43 @@ func (e *KindClient) buildStatefulset(nacos *nacosgroupv1alpha1.Nacos) *appv1.St
 				},
 			},
 			ObjectMeta: metav1.ObjectMeta{
-				Name:   "db",
+				Name:   DATA_VOLUME_NAME,
 				Labels: labels,
 			},
 		})
 
 		localVolum := v1.VolumeMount{
-			Name:      "db",
+			Name:      DATA_VOLUME_NAME,
 			MountPath: "/home/nacos/data",
 		}
 		ss.Spec.Template.Spec.Containers[0].VolumeMounts = append(ss.Spec.Template.Spec.Containers[0].VolumeMounts, localVolum)
 	}
 
-	//probe := &v1.Probe{
-	//	InitialDelaySeconds: 10,
-	//	PeriodSeconds:       5,
-	//	TimeoutSeconds:      4,
-	//	FailureThreshold:    5,
-	//	Handler: v1.Handler{
-	//		HTTPGet: &v1.HTTPGetAction{
-	//			Port: intstr.IntOrString{IntVal: NACOS_PORT},
-	//			Path: "/nacos/actuator/health/",
-	//		},
-	//		//TCPSocket: &v1.TCPSocketAction{
-	//		//	Port: intstr.IntOrString{IntVal: NACOS_PORT},
-	//		//},
-	//	},
-	//}
-
-	//if nacos.Spec.LivenessProbe == nil {
-	//	ss.Spec.Template.Spec.Containers[0].LivenessProbe = probe
-	//}
-	//if nacos.Spec.ReadinessProbe == nil {
-	//	ss.Spec.Template.Spec.Containers[0].ReadinessProbe = probe
-	//}
+	if isNacos2(nacos) {
+		container := &ss.Spec.Template.Spec.Containers[0]
+		for _, port := range e.grpcServicePorts(nacos, true) {
+			container.Ports = append(container.Ports, v1.ContainerPort{
+				Name:          port.Name,
+				ContainerPort: port.Port,
+				Protocol:      port.Protocol,
+			})
+		}
+	}
+
+	// 未配置的probe使用默认值，每个probe可以单独覆盖
+	container := &ss.Spec.Template.Spec.Containers[0]
+	if container.ReadinessProbe == nil {
+		container.ReadinessProbe = e.defaultProbe(nacos, READINESS_PATH, 5, 3)
+	}
+	if container.LivenessProbe == nil {
+		container.LivenessProbe = e.defaultProbe(nacos, LIVENESS_PATH, 10, 6)
+	}
+	container.StartupProbe = nacos.Spec.StartupProbe
+	if container.StartupProbe == nil {
+		container.StartupProbe = e.defaultProbe(nacos, LIVENESS_PATH, 10, STARTUP_FAILURE_THRESHOLD)
+	}
+
+	e.applyTLS(nacos, &ss.Spec.Template.Spec)
 
 	if nacos.Spec.Config != "" {
 		ss.Spec.Template.Spec.Volumes = append(ss.Spec.Template.Spec.Volumes, v1.Volume{
@@ -582,11 +1285,13 @@ func (e *KindClient) buildStatefulset(nacos *nacosgroupv1alpha1.Nacos) *appv1.St
 			SubPath:   "custom.properties",
 		})
 	}
-	myErrors.EnsureNormal(controllerutil.SetControllerReference(nacos, ss, e.scheme))
-	return ss
+	if err := controllerutil.SetControllerReference(nacos, ss, e.scheme); err != nil {
+		return nil, err
+	}
+	return ss, nil
 }
 
-func (e *KindClient) buildConfigMap(nacos *nacosgroupv1alpha1.Nacos) *v1.ConfigMap {
+func (e *KindClient) buildConfigMap(nacos *nacosgroupv1alpha1.Nacos) (*v1.ConfigMap, error) {
 	labels := e.generateLabels(nacos.Name, NACOS)
 	labels = e.MergeLabels(nacos.Labels, labels)
 	data := make(map[string]string)
@@ -602,95 +1307,71 @@ func (e *KindClient) buildConfigMap(nacos *nacosgroupv1alpha1.Nacos) *v1.ConfigM
 		},
 		Data: data,
 	}
-	myErrors.EnsureNormal(controllerutil.SetControllerReference(nacos, &cm, e.scheme))
-	return &cm
+	if err := controllerutil.SetControllerReference(nacos, &cm, e.scheme); err != nil {
+		return nil, err
+	}
+	return &cm, nil
 }
 
-func (e *KindClient) buildDefaultConfigMap(nacos *nacosgroupv1alpha1.Nacos) *v1.ConfigMap {
+func (e *KindClient) buildStatefulsetCluster(nacos *nacosgroupv1alpha1.Nacos, ss *appv1.StatefulSet) *appv1.StatefulSet {
+	ss.Spec.ServiceName = e.generateHeadlessSvcName(nacos)
+	container := &ss.Spec.Template.Spec.Containers[0]
+
+	// 使用文件方式寻址，集群成员以cluster.conf为准
+	appendJavaOpt(container, "-Dnacos.core.member.lookup.type=file")
+
+	ss.Spec.Template.Spec.Volumes = append(ss.Spec.Template.Spec.Volumes, v1.Volume{
+		Name: "cluster-conf",
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: e.generateClusterConfName(nacos)},
+			},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+		Name:      "cluster-conf",
+		MountPath: CLUSTER_CONF_DIR,
+	})
+	// 先检查域名解析再启动
+	container.Command = []string{"bash", "-c", fmt.Sprintf(initScrit, CLUSTER_CONF_DIR, CLUSTER_CONF_KEY)}
+	return ss
+}
+
+// clusterMembers 生成前n个pod对应的集群成员地址
+func (e *KindClient) clusterMembers(nacos *nacosgroupv1alpha1.Nacos, n int32) []string {
+	members := []string{}
+	for i := 0; i < int(n); i++ {
+		members = append(members, fmt.Sprintf("%v-%d.%v.%v.%v:%v", e.generateName(nacos), i, e.generateHeadlessSvcName(nacos), nacos.Namespace, "svc.cluster.local", nacos.ServerPort()))
+	}
+	return members
+}
+
+func (e *KindClient) buildClusterConfigMap(nacos *nacosgroupv1alpha1.Nacos, n int32) (*v1.ConfigMap, error) {
 	labels := e.generateLabels(nacos.Name, NACOS)
 	labels = e.MergeLabels(nacos.Labels, labels)
-	data := make(map[string]string)
-
-	// https://github.com/nacos-group/nacos-docker/blob/master/build/conf/application.properties
-	data["application.properties"] = `# spring
-	server.servlet.contextPath=${SERVER_SERVLET_CONTEXTPATH:/nacos}
-	server.contextPath=/nacos
-	server.port=${NACOS_APPLICATION_PORT:8848}
-	spring.datasource.platform=${SPRING_DATASOURCE_PLATFORM:""}
-	nacos.cmdb.dumpTaskInterval=3600
-	nacos.cmdb.eventTaskInterval=10
-	nacos.cmdb.labelTaskInterval=300
-	nacos.cmdb.loadDataAtStart=false
-	db.num=${MYSQL_DATABASE_NUM:1}
-	db.url.0=jdbc:mysql://${MYSQL_SERVICE_HOST}:${MYSQL_SERVICE_PORT:3306}/${MYSQL_SERVICE_DB_NAME}?${MYSQL_SERVICE_DB_PARAM:characterEncoding=utf8&connectTimeout=1000&socketTimeout=3000&autoReconnect=true}
-	db.url.1=jdbc:mysql://${MYSQL_SERVICE_HOST}:${MYSQL_SERVICE_PORT:3306}/${MYSQL_SERVICE_DB_NAME}?${MYSQL_SERVICE_DB_PARAM:characterEncoding=utf8&connectTimeout=1000&socketTimeout=3000&autoReconnect=true}
-	db.user=${MYSQL_SERVICE_USER}
-	db.password=${MYSQL_SERVICE_PASSWORD}
-	### The auth system to use, currently only 'nacos' is supported:
-	nacos.core.auth.system.type=${NACOS_AUTH_SYSTEM_TYPE:nacos}
-	
-	
-	### The token expiration in seconds:
-	nacos.core.auth.default.token.expire.seconds=${NACOS_AUTH_TOKEN_EXPIRE_SECONDS:18000}
-	
-	### The default token:
-	nacos.core.auth.default.token.secret.key=${NACOS_AUTH_TOKEN:SecretKey012345678901234567890123456789012345678901234567890123456789}
-	
-	### Turn on/off caching of auth information. By turning on this switch, the update of auth information would have a 15 seconds delay.
-	nacos.core.auth.caching.enabled=${NACOS_AUTH_CACHE_ENABLE:false}
-	nacos.core.auth.enable.userAgentAuthWhite=${NACOS_AUTH_USER_AGENT_AUTH_WHITE_ENABLE:false}
-	nacos.core.auth.server.identity.key=${NACOS_AUTH_IDENTITY_KEY:serverIdentity}
-	nacos.core.auth.server.identity.value=${NACOS_AUTH_IDENTITY_VALUE:security}
-	server.tomcat.accesslog.enabled=${TOMCAT_ACCESSLOG_ENABLED:false}
-	server.tomcat.accesslog.pattern=%h %l %u %t "%r" %s %b %D
-	# default current work dir
-	server.tomcat.basedir=
-	## spring security config
-	### turn off security
-	nacos.security.ignore.urls=${NACOS_SECURITY_IGNORE_URLS:/,/error,/**/*.css,/**/*.js,/**/*.html,/**/*.map,/**/*.svg,/**/*.png,/**/*.ico,/console-fe/public/**,/v1/auth/**,/v1/console/health/**,/actuator/**,/v1/console/server/**}
-	# metrics for elastic search
-	management.metrics.export.elastic.enabled=false
-	management.metrics.export.influx.enabled=false
-	
-	nacos.naming.distro.taskDispatchThreadCount=10
-	nacos.naming.distro.taskDispatchPeriod=200
-	nacos.naming.distro.batchSyncKeyCount=1000
-	nacos.naming.distro.initDataRatio=0.9
-	nacos.naming.distro.syncRetryDelay=5000
-	nacos.naming.data.warmup=true`
 
-	cm := v1.ConfigMap{
+	cm := &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        fmt.Sprintf("%s-default", e.generateName(nacos)),
-			Namespace:   nacos.Namespace,
-			Labels:      labels,
-			Annotations: nacos.Annotations,
+			Name:      e.generateClusterConfName(nacos),
+			Namespace: nacos.Namespace,
+			Labels:    labels,
 		},
-		Data: data,
+		Data: map[string]string{
+			CLUSTER_CONF_KEY: strings.Join(e.clusterMembers(nacos, n), "\n") + "\n",
+		},
+	}
+	if err := controllerutil.SetControllerReference(nacos, cm, e.scheme); err != nil {
+		return nil, err
 	}
-	myErrors.EnsureNormal(controllerutil.SetControllerReference(nacos, &cm, e.scheme))
-	return &cm
+	return cm, nil
 }
 
-func (e *KindClient) buildStatefulsetCluster(nacos *nacosgroupv1alpha1.Nacos, ss *appv1.StatefulSet) *appv1.StatefulSet {
-	ss.Spec.ServiceName = e.generateHeadlessSvcName(nacos)
-	serivce := ""
-	serivceNoPort := ""
-	for i := 0; i < int(*nacos.Spec.Replicas); i++ {
-		serivce = fmt.Sprintf("%v%v-%d.%v.%v.%v:%v ", serivce, e.generateName(nacos), i, e.generateHeadlessSvcName(nacos), nacos.Namespace, "svc.cluster.local", NACOS_PORT)
-		serivceNoPort = fmt.Sprintf("%v%v-%d.%v.%v.%v ", serivceNoPort, e.generateName(nacos), i, e.generateHeadlessSvcName(nacos), nacos.Namespace, "svc.cluster.local")
-	}
-	serivce = serivce[0 : len(serivce)-1]
-	env := []v1.EnvVar{
-		{
-			Name:  "NACOS_SERVERS",
-			Value: serivce,
-		},
+// 集群当前的成员数，扩缩容完成前与spec.replicas不同
+func memberReplicas(nacos *nacosgroupv1alpha1.Nacos) int32 {
+	if nacos.Status.Replicas > 0 {
+		return nacos.Status.Replicas
 	}
-	ss.Spec.Template.Spec.Containers[0].Env = append(ss.Spec.Template.Spec.Containers[0].Env, env...)
-	// 先检查域名解析再启动
-	ss.Spec.Template.Spec.Containers[0].Command = []string{"sh", "-c", fmt.Sprintf("%s&&bin/docker-startup.sh", fmt.Sprintf(initScrit, serivceNoPort))}
-	return ss
+	return *nacos.Spec.Replicas
 }
 
 func (e *KindClient) buildHeadlessServiceCluster(svc *v1.Service, nacos *nacosgroupv1alpha1.Nacos) *v1.Service {
@@ -698,3 +1379,83 @@ func (e *KindClient) buildHeadlessServiceCluster(svc *v1.Service, nacos *nacosgr
 	svc.Name = e.generateHeadlessSvcName(nacos)
 	return svc
 }
+
+// defaultProbe 访问nacos健康检查接口的probe，开启tls时由applyTLS切换为https
+func (e *KindClient) defaultProbe(nacos *nacosgroupv1alpha1.Nacos, path string, periodSeconds int32, failureThreshold int32) *v1.Probe {
+	return &v1.Probe{
+		PeriodSeconds:    periodSeconds,
+		TimeoutSeconds:   3,
+		SuccessThreshold: 1,
+		FailureThreshold: failureThreshold,
+		Handler: v1.Handler{
+			HTTPGet: &v1.HTTPGetAction{
+				Port:   intstr.FromInt(int(nacos.ServerPort())),
+				Path:   nacos.ServerContextPath() + path,
+				Scheme: v1.URISchemeHTTP,
+			},
+		},
+	}
+}
+
+// isNacos2 2.x需要额外的grpc端口和新的集群接口，无法从版本判断时按2.x处理，
+// 1.x上多出的端口不会被使用
+func isNacos2(nacos *nacosgroupv1alpha1.Nacos) bool {
+	major := nacos.MajorVersion()
+	return major == 0 || major >= 2
+}
+
+// getClusterNodes 按版本选择集群接口
+func (e *KindClient) getClusterNodes(cli *nacosClient.NacosClient, nacos *nacosgroupv1alpha1.Nacos, ip string) (nacosClient.ServersInfo, error) {
+	return e.healthModel(nacos).ClusterNodes(cli, ip)
+}
+
+// grpcServicePorts 2.x的grpc端口，server为true时包含集群成员之间通信的端口
+func (e *KindClient) grpcServicePorts(nacos *nacosgroupv1alpha1.Nacos, server bool) []v1.ServicePort {
+	if !isNacos2(nacos) {
+		return nil
+	}
+	ports := []v1.ServicePort{
+		{
+			Name:     "client-grpc",
+			Port:     nacos.ServerPort() + CLIENT_GRPC_PORT_OFFSET,
+			Protocol: "TCP",
+		},
+	}
+	if server {
+		ports = append(ports, v1.ServicePort{
+			Name:     "server-grpc",
+			Port:     nacos.ServerPort() + SERVER_GRPC_PORT_OFFSET,
+			Protocol: "TCP",
+		})
+	}
+	return ports
+}
+
+// appendJavaOpt 追加jvm参数到JAVA_OPT_EXT，保留用户在spec.env中配置的参数
+func appendJavaOpt(container *v1.Container, opt string) {
+	env := []v1.EnvVar{}
+	found := false
+	for _, item := range container.Env {
+		if item.Name == "JAVA_OPT_EXT" {
+			item.Value = strings.TrimSpace(item.Value + " " + opt)
+			found = true
+		}
+		env = append(env, item)
+	}
+	if !found {
+		env = append(env, v1.EnvVar{
+			Name:  "JAVA_OPT_EXT",
+			Value: opt,
+		})
+	}
+	container.Env = env
+}
+
+func containsEnv(env []v1.EnvVar, name string) bool {
+	for _, item := range env {
+		if item.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package operator
+
+import (
+	"testing"
+
+	appv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"nacos.io/nacos-operator/pkg/service/k8s"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// fakeConfigMaps 只实现configmap相关的接口，其他接口调用时会panic
+type fakeConfigMaps struct {
+	k8s.Services
+	configMaps map[string]*v1.ConfigMap
+}
+
+func (f *fakeConfigMaps) GetConfigMap(namespace string, name string) (*v1.ConfigMap, error) {
+	cm, ok := f.configMaps[name]
+	if !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	return cm.DeepCopy(), nil
+}
+
+func (f *fakeConfigMaps) CreateConfigMap(namespace string, cm *v1.ConfigMap) error {
+	f.configMaps[cm.Name] = cm.DeepCopy()
+	return nil
+}
+
+func (f *fakeConfigMaps) CreateOrUpdateConfigMap(namespace string, cm *v1.ConfigMap) error {
+	f.configMaps[cm.Name] = cm.DeepCopy()
+	return nil
+}
+
+func testStatefulset() *appv1.StatefulSet {
+	replicas := int32(3)
+	return &appv1.StatefulSet{
+		Spec: appv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: "nacos-headless",
+			Selector:    &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nacos"}},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "nacos"}},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{Name: "nacos", Image: "nacos/nacos-server:1.4.1"}},
+				},
+			},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{testClaim("db", "", "1Gi")},
+		},
+	}
+}
+
+func testClaim(name string, storageClass string, size string) v1.PersistentVolumeClaim {
+	claim := v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+	if storageClass != "" {
+		claim.Spec.StorageClassName = &storageClass
+	}
+	return claim
+}
+
+func TestSpecHash(t *testing.T) {
+	e := &KindClient{}
+	tests := []struct {
+		name    string
+		mutate  func(ss *appv1.StatefulSet)
+		changed bool
+	}{
+		{"unchanged", func(ss *appv1.StatefulSet) {}, false},
+		{"replicas", func(ss *appv1.StatefulSet) {
+			replicas := int32(5)
+			ss.Spec.Replicas = &replicas
+		}, false},
+		{"updateStrategy", func(ss *appv1.StatefulSet) { ss.Spec.UpdateStrategy = rollingUpdateStrategy(2) }, false},
+		{"volumeClaimTemplates", func(ss *appv1.StatefulSet) {
+			ss.Spec.VolumeClaimTemplates = []v1.PersistentVolumeClaim{testClaim("db", "", "2Gi")}
+		}, false},
+		{"selector", func(ss *appv1.StatefulSet) { ss.Spec.Selector.MatchLabels["team"] = "a" }, false},
+		{"image", func(ss *appv1.StatefulSet) { ss.Spec.Template.Spec.Containers[0].Image = "nacos/nacos-server:2.0.3" }, true},
+		{"pod labels", func(ss *appv1.StatefulSet) { ss.Spec.Template.Labels = map[string]string{"app": "nacos", "team": "a"} }, true},
+		{"pod annotations", func(ss *appv1.StatefulSet) {
+			ss.Spec.Template.Annotations = map[string]string{ANNOTATION_SECRET_HASH: "1"}
+		}, true},
+	}
+	base, err := e.specHash(testStatefulset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := testStatefulset()
+			tt.mutate(ss)
+			hash, err := e.specHash(ss)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (hash != base) != tt.changed {
+				t.Errorf("hash changed = %v, want %v", hash != base, tt.changed)
+			}
+		})
+	}
+}
+
+func TestVolumeClaimTemplatesDrift(t *testing.T) {
+	stored := []v1.PersistentVolumeClaim{testClaim("db", "standard", "1Gi")}
+	tests := []struct {
+		name    string
+		desired []v1.PersistentVolumeClaim
+		drift   bool
+	}{
+		{"same", []v1.PersistentVolumeClaim{testClaim("db", "standard", "1Gi")}, false},
+		{"same size in other unit", []v1.PersistentVolumeClaim{testClaim("db", "standard", "1024Mi")}, false},
+		{"labels only", func() []v1.PersistentVolumeClaim {
+			claim := testClaim("db", "standard", "1Gi")
+			claim.Labels = map[string]string{"team": "a"}
+			return []v1.PersistentVolumeClaim{claim}
+		}(), false},
+		{"volume disabled", nil, true},
+		{"size", []v1.PersistentVolumeClaim{testClaim("db", "standard", "2Gi")}, true},
+		{"storageClass", []v1.PersistentVolumeClaim{testClaim("db", "fast", "1Gi")}, true},
+		{"name", []v1.PersistentVolumeClaim{testClaim("data", "standard", "1Gi")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drift := volumeClaimTemplatesDrift(stored, tt.desired)
+			if (drift != "") != tt.drift {
+				t.Errorf("drift = %q, want drift %v", drift, tt.drift)
+			}
+		})
+	}
+}
+
+func TestKeepImmutableFields(t *testing.T) {
+	stored := testStatefulset()
+	stored.Spec.Selector.MatchLabels["team"] = "a"
+	ss := testStatefulset()
+	ss.Spec.ServiceName = "other"
+	ss.Spec.VolumeClaimTemplates[0].Labels = map[string]string{"team": "b"}
+
+	keepImmutableFields(stored, ss)
+	if ss.Spec.ServiceName != "nacos-headless" {
+		t.Errorf("serviceName = %s", ss.Spec.ServiceName)
+	}
+	if ss.Spec.VolumeClaimTemplates[0].Labels != nil {
+		t.Errorf("volumeClaimTemplates labels = %v", ss.Spec.VolumeClaimTemplates[0].Labels)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(ss.Spec.Selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !selector.Matches(labels.Set(ss.Spec.Template.Labels)) {
+		t.Errorf("pod labels %v do not match selector %v", ss.Spec.Template.Labels, ss.Spec.Selector)
+	}
+}
+
+func TestEnsureConfigmap(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := nacosgroupv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeConfigMaps{configMaps: map[string]*v1.ConfigMap{}}
+	recorder := record.NewFakeRecorder(10)
+	e := NewKindClient(ctrl.Log, fake, scheme, nil, nil, recorder)
+	nacos := &nacosgroupv1alpha1.Nacos{ObjectMeta: metav1.ObjectMeta{Name: "nacos", Namespace: "default", UID: "uid"}}
+	nacos.Spec.Config = "nacos.core.auth.enabled=false"
+
+	for _, config := range []string{nacos.Spec.Config, nacos.Spec.Config, "nacos.core.auth.enabled=true"} {
+		nacos.Spec.Config = config
+		if err := e.EnsureConfigmap(nacos); err != nil {
+			t.Fatal(err)
+		}
+		cm := fake.configMaps[e.generateName(nacos)]
+		if cm == nil || cm.Data["custom.properties"] != config {
+			t.Fatalf("configmap = %v, want custom.properties %q", cm, config)
+		}
+	}
+	// 创建一次，内容未变化时不更新，变化后更新一次
+	if len(recorder.Events) != 2 {
+		t.Errorf("events = %d, want 2", len(recorder.Events))
+	}
+}
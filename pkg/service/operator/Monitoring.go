@@ -0,0 +1,211 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// nacos暴露prometheus指标的路径，相对spec.contextPath
+const METRICS_PATH = "/actuator/prometheus"
+
+// 默认抓取间隔
+const METRICS_INTERVAL = "30s"
+
+// 开启监控时暴露的actuator endpoint，可以在spec.env中覆盖
+const METRICS_EXPOSURE_ENV = "MANAGEMENT_ENDPOINTS_WEB_EXPOSURE_INCLUDE"
+const METRICS_EXPOSURE = "prometheus,health"
+
+var (
+	serviceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+	prometheusRuleGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule"}
+)
+
+// EnsureMonitoring 开启监控时维护ServiceMonitor和PrometheusRule，关闭时删除
+// 集群中没有安装prometheus-operator的crd时只记录日志，不影响reconcile
+func (e *KindClient) EnsureMonitoring(nacos *nacosgroupv1alpha1.Nacos) error {
+	objs := []*unstructured.Unstructured{}
+	if nacos.Spec.Monitoring.Enabled {
+		sm, err := e.buildServiceMonitor(nacos)
+		if err != nil {
+			return err
+		}
+		rule, err := e.buildPrometheusRule(nacos)
+		if err != nil {
+			return err
+		}
+		objs = append(objs, sm, rule)
+	}
+
+	for _, gvk := range []schema.GroupVersionKind{serviceMonitorGVK, prometheusRuleGVK} {
+		var desired *unstructured.Unstructured
+		for _, obj := range objs {
+			if obj.GroupVersionKind() == gvk {
+				desired = obj
+			}
+		}
+		err := e.ensureUnstructured(nacos, gvk, desired)
+		if meta.IsNoMatchError(err) {
+			if nacos.Spec.Monitoring.Enabled {
+				e.logger.V(0).Info("prometheus operator crd not found, skip monitoring", "kind", gvk.Kind,
+					"namespace", nacos.Namespace, "name", nacos.Name)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureUnstructured desired为空时删除已有的对象，否则创建或更新
+func (e *KindClient) ensureUnstructured(nacos *nacosgroupv1alpha1.Nacos, gvk schema.GroupVersionKind, desired *unstructured.Unstructured) error {
+	stored := &unstructured.Unstructured{}
+	stored.SetGroupVersionKind(gvk)
+	err := e.client.Get(context.TODO(), types.NamespacedName{Namespace: nacos.Namespace, Name: e.generateName(nacos)}, stored)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	// 只删除operator创建的对象
+	if desired == nil {
+		if exists && isControlledBy(stored, nacos) {
+			if err := e.client.Delete(context.TODO(), stored); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	if !exists {
+		return e.client.Create(context.TODO(), desired)
+	}
+	if reflect.DeepEqual(stored.Object["spec"], desired.Object["spec"]) && reflect.DeepEqual(stored.GetLabels(), desired.GetLabels()) {
+		return nil
+	}
+	stored.Object["spec"] = desired.Object["spec"]
+	stored.SetLabels(desired.GetLabels())
+	return e.client.Update(context.TODO(), stored)
+}
+
+func isControlledBy(obj *unstructured.Unstructured, nacos *nacosgroupv1alpha1.Nacos) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == nacos.UID && ref.Controller != nil && *ref.Controller {
+			return true
+		}
+	}
+	return false
+}
+
+// metricsSvcName 抓取指标的service，集群模式使用headless service，每个pod一个target
+func (e *KindClient) metricsSvcName(nacos *nacosgroupv1alpha1.Nacos) string {
+	if nacos.Spec.Type == TYPE_CLUSTER {
+		return e.generateHeadlessSvcName(nacos)
+	}
+	return e.generateName(nacos)
+}
+
+func (e *KindClient) buildMonitoringObject(nacos *nacosgroupv1alpha1.Nacos, gvk schema.GroupVersionKind, spec map[string]interface{}) (*unstructured.Unstructured, error) {
+	labels := e.generateLabels(nacos.Name, NACOS)
+	labels = e.MergeLabels(nacos.Labels, labels, nacos.Spec.Monitoring.Labels)
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(e.generateName(nacos))
+	obj.SetNamespace(nacos.Namespace)
+	obj.SetLabels(labels)
+	if err := controllerutil.SetControllerReference(nacos, obj, e.scheme); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (e *KindClient) buildServiceMonitor(nacos *nacosgroupv1alpha1.Nacos) (*unstructured.Unstructured, error) {
+	interval := nacos.Spec.Monitoring.Interval
+	if interval == "" {
+		interval = METRICS_INTERVAL
+	}
+	// 所有service的label相同，通过relabel只保留需要抓取的service
+	selector := map[string]interface{}{}
+	for k, v := range e.generateLabels(nacos.Name, NACOS) {
+		selector[k] = v
+	}
+	endpoint := map[string]interface{}{
+		"port":     "client",
+		"path":     nacos.ServerContextPath() + METRICS_PATH,
+		"interval": interval,
+		"relabelings": []interface{}{
+			map[string]interface{}{
+				"sourceLabels": []interface{}{"__meta_kubernetes_service_name"},
+				"regex":        e.metricsSvcName(nacos),
+				"action":       "keep",
+			},
+		},
+	}
+	// 开启tls时使用证书中的ca校验，prometheus通过pod ip抓取，使用access service的域名校验
+	if nacos.Spec.TLS.Enabled {
+		endpoint["scheme"] = "https"
+		endpoint["tlsConfig"] = map[string]interface{}{
+			"ca": map[string]interface{}{
+				"secret": map[string]interface{}{
+					"name": e.generateTLSSecretName(nacos),
+					"key":  TLS_CA_KEY,
+				},
+			},
+			"serverName": e.generateAccessAddress(nacos),
+		}
+	}
+	spec := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": selector,
+		},
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{nacos.Namespace},
+		},
+		"endpoints": []interface{}{endpoint},
+	}
+	return e.buildMonitoringObject(nacos, serviceMonitorGVK, spec)
+}
+
+func (e *KindClient) buildPrometheusRule(nacos *nacosgroupv1alpha1.Nacos) (*unstructured.Unstructured, error) {
+	selector := fmt.Sprintf(`namespace="%s",service="%s"`, nacos.Namespace, e.metricsSvcName(nacos))
+	rule := func(alert string, expr string, description string) interface{} {
+		return map[string]interface{}{
+			"alert": alert,
+			"expr":  expr,
+			"for":   "1m",
+			"labels": map[string]interface{}{
+				"severity": "critical",
+			},
+			"annotations": map[string]interface{}{
+				"summary":     fmt.Sprintf("Nacos %s/%s instance {{ $labels.instance }}", nacos.Namespace, nacos.Name),
+				"description": description,
+			},
+		}
+	}
+	exception := func(name string) string {
+		return fmt.Sprintf(`increase(nacos_exception_total{name="%s",%s}[1m]) > 0`, name, selector)
+	}
+	spec := map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{
+				"name": fmt.Sprintf("nacos-%s-%s", nacos.Namespace, nacos.Name),
+				"rules": []interface{}{
+					rule("NacosDown", fmt.Sprintf("up{%s} == 0", selector), "nacos down"),
+					rule("NacosDBException", exception("db"), "nacos db exception"),
+					rule("NacosDiskException", exception("disk"), "nacos disk exception"),
+					rule("NacosBeatException", exception("leaderSendBeatFailed"), "nacos beat exception"),
+				},
+			},
+		},
+	}
+	return e.buildMonitoringObject(nacos, prometheusRuleGVK, spec)
+}
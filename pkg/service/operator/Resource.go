@@ -0,0 +1,616 @@
+package operator
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/go-logr/logr"
+	"golang.org/x/crypto/bcrypt"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"nacos.io/nacos-operator/pkg/service/k8s"
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// 删除cr时先删除nacos中对应的命名空间、用户、角色、权限或配置
+const RESOURCE_CLEANUP_FINALIZER = "nacos.io/resource-cleanup"
+
+// 同步成功后定期重新检查，发现控制台中的修改并恢复
+const RESOURCE_RESYNC_INTERVAL = time.Minute * 5
+
+// 未指定group时使用的默认分组
+const DEFAULT_GROUP = "DEFAULT_GROUP"
+
+// 删除cr时跳过nacos中数据的清理，用于nacos已经无法恢复的情况
+const ANNOTATION_SKIP_CLEANUP = "nacos.io/skip-cleanup"
+
+// public命名空间不能通过cr管理
+const PUBLIC_NAMESPACE = "public"
+
+type IResourceClient interface {
+	MakeNamespace(namespace *nacosgroupv1alpha1.NacosNamespace) (time.Duration, error)
+	MakeUser(user *nacosgroupv1alpha1.NacosUser) (time.Duration, error)
+	MakeRole(role *nacosgroupv1alpha1.NacosRole) (time.Duration, error)
+	MakePermission(permission *nacosgroupv1alpha1.NacosPermission) (time.Duration, error)
+	MakeConfig(config *nacosgroupv1alpha1.NacosConfig) (time.Duration, error)
+}
+
+type ResourceClient struct {
+	k8sService k8s.Services
+	logger     log.Logger
+	client     client.Client
+	kindClient *KindClient
+}
+
+func NewResourceClient(logger log.Logger, k8sService k8s.Services, client client.Client, kindClient *KindClient) *ResourceClient {
+	return &ResourceClient{
+		k8sService: k8sService,
+		logger:     logger,
+		client:     client,
+		kindClient: kindClient,
+	}
+}
+
+// nacosResource NacosNamespace、NacosUser、NacosRole、NacosPermission、NacosConfig
+type nacosResource interface {
+	runtime.Object
+	metav1.Object
+}
+
+// MakeNamespace 创建命名空间，显示名称和描述被修改后恢复；namespaceId修改后删除原来的命名空间
+func (c *ResourceClient) MakeNamespace(namespace *nacosgroupv1alpha1.NacosNamespace) (time.Duration, error) {
+	desired := nacosClient.Namespace{
+		Namespace:         namespace.Spec.NamespaceId,
+		NamespaceShowName: namespace.Spec.ShowName,
+		NamespaceDesc:     namespace.Spec.Description,
+	}
+	if desired.Namespace == "" {
+		desired.Namespace = namespace.Name
+	}
+	if desired.NamespaceShowName == "" {
+		desired.NamespaceShowName = desired.Namespace
+	}
+	deleteNamespace := func(cli *nacosClient.NacosClient, ip string, id string) error {
+		if id == PUBLIC_NAMESPACE {
+			return nil
+		}
+		if err := cli.DeleteNamespace(ip, id); err != nil && !nacosClient.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	sync := func(cli *nacosClient.NacosClient, ip string) error {
+		if desired.Namespace == PUBLIC_NAMESPACE {
+			return fmt.Errorf("namespace %s can not be managed", PUBLIC_NAMESPACE)
+		}
+		if applied := namespace.Status.NamespaceId; applied != "" && applied != desired.Namespace {
+			if err := deleteNamespace(cli, ip, applied); err != nil {
+				return err
+			}
+			namespace.Status.NamespaceId = ""
+		}
+		namespaces, err := cli.GetNamespaces(ip)
+		if err != nil {
+			return err
+		}
+		for _, actual := range namespaces {
+			if actual.Namespace != desired.Namespace {
+				continue
+			}
+			namespace.Status.NamespaceId = desired.Namespace
+			if actual.NamespaceShowName == desired.NamespaceShowName && actual.NamespaceDesc == desired.NamespaceDesc {
+				return nil
+			}
+			return cli.UpdateNamespace(ip, desired)
+		}
+		if err := cli.CreateNamespace(ip, desired); err != nil {
+			return err
+		}
+		namespace.Status.NamespaceId = desired.Namespace
+		return nil
+	}
+	cleanup := func(cli *nacosClient.NacosClient, ip string) error {
+		return deleteNamespace(cli, ip, appliedOr(namespace.Status.NamespaceId, desired.Namespace))
+	}
+	return c.syncResource(namespace, namespace.Spec.NacosName, &namespace.Status.NacosResourceStatus, sync, cleanup)
+}
+
+// MakeUser 创建用户，secret中的密码变化或者在控制台被修改后重新设置密码；username修改后删除原来的用户
+func (c *ResourceClient) MakeUser(user *nacosgroupv1alpha1.NacosUser) (time.Duration, error) {
+	username := user.Spec.Username
+	if username == "" {
+		username = user.Name
+	}
+	deleteUser := func(cli *nacosClient.NacosClient, ip string, name string) error {
+		// 删除管理员会导致无法登录，只保留
+		if name == NACOS_ADMIN_USER {
+			return nil
+		}
+		if err := cli.DeleteUser(ip, name); err != nil && !nacosClient.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	sync := func(cli *nacosClient.NacosClient, ip string) error {
+		// 管理员的密码由nacos的spec.auth维护
+		if username == NACOS_ADMIN_USER {
+			return fmt.Errorf("user %s is managed by spec.auth of nacos %s", NACOS_ADMIN_USER, user.Spec.NacosName)
+		}
+		password, err := c.getPassword(user)
+		if err != nil {
+			return err
+		}
+		if applied := user.Status.Username; applied != "" && applied != username {
+			if err := deleteUser(cli, ip, applied); err != nil {
+				return err
+			}
+			user.Status.Username = ""
+		}
+		users, err := cli.ListUsers(ip)
+		if err != nil {
+			return err
+		}
+		for _, actual := range users {
+			if actual.Username != username {
+				continue
+			}
+			user.Status.Username = username
+			// 部分版本不返回密码，比较失败时直接重新设置
+			if bcrypt.CompareHashAndPassword([]byte(actual.Password), password) == nil {
+				return nil
+			}
+			return cli.UpdateUserPassword(ip, username, string(password))
+		}
+		if err := cli.CreateUser(ip, username, string(password)); err != nil {
+			return err
+		}
+		user.Status.Username = username
+		return nil
+	}
+	cleanup := func(cli *nacosClient.NacosClient, ip string) error {
+		return deleteUser(cli, ip, appliedOr(user.Status.Username, username))
+	}
+	return c.syncResource(user, user.Spec.NacosName, &user.Status.NacosResourceStatus, sync, cleanup)
+}
+
+func (c *ResourceClient) getPassword(user *nacosgroupv1alpha1.NacosUser) ([]byte, error) {
+	ref := user.Spec.PasswordSecretRef
+	secret, err := c.k8sService.GetSecret(user.Namespace, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	password, ok := secret.Data[ref.Key]
+	if !ok || len(password) == 0 {
+		return nil, fmt.Errorf("secret %s has no key %s", ref.Name, ref.Key)
+	}
+	return password, nil
+}
+
+// MakeRole 绑定spec.users中的用户，解绑从spec.users中移除的用户，控制台中手动绑定的用户不受影响；
+// role修改后解绑原来角色的所有用户
+func (c *ResourceClient) MakeRole(role *nacosgroupv1alpha1.NacosRole) (time.Duration, error) {
+	name := role.Spec.Role
+	if name == "" {
+		name = role.Name
+	}
+	desired := toSet(role.Spec.Users)
+	applied := toSet(role.Status.Users)
+	// 解绑applied中的用户，部分失败时保留未解绑的用户
+	unbindAll := func(cli *nacosClient.NacosClient, ip string, roleName string) error {
+		for _, username := range fromSet(applied) {
+			if err := cli.DeleteRole(ip, roleName, username); err != nil && !nacosClient.IsNotFound(err) {
+				return err
+			}
+			delete(applied, username)
+		}
+		return nil
+	}
+
+	sync := func(cli *nacosClient.NacosClient, ip string) error {
+		appliedRole := name
+		// 部分失败时也记录已经绑定的用户，原来的角色没有解绑完成时继续记录原来的角色
+		defer func() {
+			role.Status.Users = fromSet(applied)
+			role.Status.Role = ""
+			if len(applied) > 0 {
+				role.Status.Role = appliedRole
+			}
+		}()
+		if old := role.Status.Role; old != "" && old != name {
+			appliedRole = old
+			if err := unbindAll(cli, ip, old); err != nil {
+				return err
+			}
+			appliedRole = name
+		}
+		bindings, err := cli.ListRoles(ip, "")
+		if err != nil {
+			return err
+		}
+		actual := map[string]bool{}
+		for _, binding := range bindings {
+			if binding.Role == name {
+				actual[binding.Username] = true
+			}
+		}
+		for _, username := range fromSet(desired) {
+			if !actual[username] {
+				if err := cli.CreateRole(ip, name, username); err != nil {
+					return err
+				}
+			}
+			applied[username] = true
+		}
+		for _, username := range fromSet(applied) {
+			if desired[username] {
+				continue
+			}
+			if actual[username] {
+				if err := cli.DeleteRole(ip, name, username); err != nil && !nacosClient.IsNotFound(err) {
+					return err
+				}
+			}
+			delete(applied, username)
+		}
+		return nil
+	}
+	cleanup := func(cli *nacosClient.NacosClient, ip string) error {
+		roleName := appliedOr(role.Status.Role, name)
+		if roleName == name {
+			for username := range desired {
+				applied[username] = true
+			}
+		}
+		return unbindAll(cli, ip, roleName)
+	}
+	return c.syncResource(role, role.Spec.NacosName, &role.Status.NacosResourceStatus, sync, cleanup)
+}
+
+// MakePermission 授予spec.permissions中的权限，回收从spec.permissions中移除的权限，控制台中手动授予的权限不受影响；
+// role修改后回收原来角色的所有权限
+func (c *ResourceClient) MakePermission(permission *nacosgroupv1alpha1.NacosPermission) (time.Duration, error) {
+	role := permission.Spec.Role
+	desired := map[nacosgroupv1alpha1.Permission]bool{}
+	for _, p := range permission.Spec.Permissions {
+		desired[p] = true
+	}
+	applied := map[nacosgroupv1alpha1.Permission]bool{}
+	for _, p := range permission.Status.Permissions {
+		applied[p] = true
+	}
+	toNacos := func(roleName string, p nacosgroupv1alpha1.Permission) nacosClient.Permission {
+		return nacosClient.Permission{Role: roleName, Resource: p.Resource, Action: p.Action}
+	}
+	// 回收applied中的权限，部分失败时保留未回收的权限
+	revokeAll := func(cli *nacosClient.NacosClient, ip string, roleName string) error {
+		for _, p := range sortPermissions(applied) {
+			if err := cli.DeletePermission(ip, toNacos(roleName, p)); err != nil && !nacosClient.IsNotFound(err) {
+				return err
+			}
+			delete(applied, p)
+		}
+		return nil
+	}
+
+	sync := func(cli *nacosClient.NacosClient, ip string) error {
+		appliedRole := role
+		// 部分失败时也记录已经授予的权限，原来的角色没有回收完成时继续记录原来的角色
+		defer func() {
+			permission.Status.Permissions = sortPermissions(applied)
+			permission.Status.Role = ""
+			if len(applied) > 0 {
+				permission.Status.Role = appliedRole
+			}
+		}()
+		if old := permission.Status.Role; old != "" && old != role {
+			appliedRole = old
+			if err := revokeAll(cli, ip, old); err != nil {
+				return err
+			}
+			appliedRole = role
+		}
+		permissions, err := cli.ListPermissions(ip, role)
+		if err != nil {
+			return err
+		}
+		actual := map[nacosgroupv1alpha1.Permission]bool{}
+		for _, p := range permissions {
+			actual[nacosgroupv1alpha1.Permission{Resource: p.Resource, Action: p.Action}] = true
+		}
+		for _, p := range sortPermissions(desired) {
+			if !actual[p] {
+				if err := cli.CreatePermission(ip, toNacos(role, p)); err != nil {
+					return err
+				}
+			}
+			applied[p] = true
+		}
+		for _, p := range sortPermissions(applied) {
+			if desired[p] {
+				continue
+			}
+			if actual[p] {
+				if err := cli.DeletePermission(ip, toNacos(role, p)); err != nil && !nacosClient.IsNotFound(err) {
+					return err
+				}
+			}
+			delete(applied, p)
+		}
+		return nil
+	}
+	cleanup := func(cli *nacosClient.NacosClient, ip string) error {
+		roleName := appliedOr(permission.Status.Role, role)
+		if roleName == role {
+			for p := range desired {
+				applied[p] = true
+			}
+		}
+		return revokeAll(cli, ip, roleName)
+	}
+	return c.syncResource(permission, permission.Spec.NacosName, &permission.Status.NacosResourceStatus, sync, cleanup)
+}
+
+// MakeConfig 发布配置，md5不一致时才重新发布；设置betaIps时只做灰度发布，清空后发布正式配置并停止灰度
+func (c *ResourceClient) MakeConfig(config *nacosgroupv1alpha1.NacosConfig) (time.Duration, error) {
+	desired := nacosClient.ConfigItem{
+		Tenant:  config.Spec.NamespaceId,
+		Group:   config.Spec.Group,
+		DataId:  config.Spec.DataId,
+		Type:    config.Spec.Type,
+		AppName: config.Spec.AppName,
+	}
+	if desired.Group == "" {
+		desired.Group = DEFAULT_GROUP
+	}
+	// 删除配置，正在灰度时先停止灰度
+	deleteConfig := func(cli *nacosClient.NacosClient, ip string, tenant string, group string, dataId string) error {
+		if config.Status.BetaMd5 != "" {
+			if err := cli.StopBetaConfig(ip, tenant, group, dataId); err != nil && !nacosClient.IsNotFound(err) {
+				return err
+			}
+		}
+		if err := cli.DeleteConfig(ip, tenant, group, dataId); err != nil && !nacosClient.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	sync := func(cli *nacosClient.NacosClient, ip string) error {
+		content, err := c.getConfigContent(config)
+		if err != nil {
+			return err
+		}
+		status := &config.Status
+		if status.DataId != "" && (status.NamespaceId != desired.Tenant || status.Group != desired.Group || status.DataId != desired.DataId) {
+			if err := deleteConfig(cli, ip, status.NamespaceId, status.Group, status.DataId); err != nil {
+				return err
+			}
+			status.NamespaceId, status.Group, status.DataId = "", "", ""
+			status.Md5, status.BetaMd5 = "", ""
+		}
+		// 发布之前记录，发布失败时删除cr也会清理
+		status.NamespaceId, status.Group, status.DataId = desired.Tenant, desired.Group, desired.DataId
+		desired.Content = content
+		md5sum := fmt.Sprintf("%x", md5.Sum([]byte(content)))
+
+		if len(config.Spec.BetaIps) > 0 {
+			beta, err := cli.GetBetaConfig(ip, desired.Tenant, desired.Group, desired.DataId)
+			if err != nil {
+				return err
+			}
+			if beta == nil || beta.Md5 != md5sum || beta.BetaIps != strings.Join(config.Spec.BetaIps, ",") {
+				if err := cli.PublishBetaConfig(ip, desired, config.Spec.BetaIps); err != nil {
+					return err
+				}
+			}
+			config.Status.BetaMd5 = md5sum
+			// 正式配置保持不变，只记录md5
+			actual, err := cli.GetConfigDetail(ip, desired.Tenant, desired.Group, desired.DataId)
+			if err != nil {
+				return err
+			}
+			config.Status.Md5 = ""
+			if actual != nil {
+				config.Status.Md5 = actual.Md5
+			}
+			return nil
+		}
+
+		actual, err := cli.GetConfigDetail(ip, desired.Tenant, desired.Group, desired.DataId)
+		if err != nil {
+			return err
+		}
+		if actual == nil || actual.Md5 != md5sum || (desired.Type != "" && actual.Type != desired.Type) {
+			if err := cli.PublishConfig(ip, desired); err != nil {
+				return err
+			}
+		}
+		config.Status.Md5 = md5sum
+		// 灰度已经转为正式发布
+		if config.Status.BetaMd5 != "" {
+			if err := cli.StopBetaConfig(ip, desired.Tenant, desired.Group, desired.DataId); err != nil && !nacosClient.IsNotFound(err) {
+				return err
+			}
+			config.Status.BetaMd5 = ""
+		}
+		return nil
+	}
+	cleanup := func(cli *nacosClient.NacosClient, ip string) error {
+		if status := config.Status; status.DataId != "" {
+			return deleteConfig(cli, ip, status.NamespaceId, status.Group, status.DataId)
+		}
+		return deleteConfig(cli, ip, desired.Tenant, desired.Group, desired.DataId)
+	}
+	return c.syncResource(config, config.Spec.NacosName, &config.Status.NacosResourceStatus, sync, cleanup)
+}
+
+func (c *ResourceClient) getConfigContent(config *nacosgroupv1alpha1.NacosConfig) (string, error) {
+	ref := config.Spec.ConfigMapRef
+	if ref == nil {
+		return config.Spec.Content, nil
+	}
+	if config.Spec.Content != "" {
+		return "", fmt.Errorf("content and configMapRef can not be set at the same time")
+	}
+	cm, err := c.k8sService.GetConfigMap(config.Namespace, ref.Name)
+	if err != nil {
+		return "", err
+	}
+	content, ok := cm.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("configmap %s has no key %s", ref.Name, ref.Key)
+	}
+	return content, nil
+}
+
+// syncResource 公共流程：处理删除和finalizer，nacos运行后调用sync同步，并记录同步状态
+func (c *ResourceClient) syncResource(obj nacosResource, nacosName string, status *nacosgroupv1alpha1.NacosResourceStatus,
+	sync func(cli *nacosClient.NacosClient, ip string) error, cleanup func(cli *nacosClient.NacosClient, ip string) error) (time.Duration, error) {
+	if obj.GetDeletionTimestamp() != nil {
+		return c.cleanupResource(obj, nacosName, status, cleanup)
+	}
+	if !containsString(obj.GetFinalizers(), RESOURCE_CLEANUP_FINALIZER) {
+		obj.SetFinalizers(append(obj.GetFinalizers(), RESOURCE_CLEANUP_FINALIZER))
+		return 0, c.client.Update(context.TODO(), obj)
+	}
+
+	stored := obj.DeepCopyObject()
+	nacos := &nacosgroupv1alpha1.Nacos{}
+	if err := c.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: nacosName}, nacos); err != nil {
+		if !errors.IsNotFound(err) {
+			return 0, err
+		}
+		status.Phase = nacosgroupv1alpha1.ResourcePhaseFailed
+		status.Message = fmt.Sprintf("nacos %s not found", nacosName)
+		return RESOURCE_RESYNC_INTERVAL, c.updateStatus(stored, obj)
+	}
+	if nacos.Status.Phase != nacosgroupv1alpha1.PhaseRunning {
+		c.logger.V(0).Info("nacos is not running, wait", "nacos", nacosName, "phase", nacos.Status.Phase)
+		return REQUEUE_INTERVAL, nil
+	}
+
+	cli, err := c.kindClient.nacosClientFor(nacos)
+	if err != nil {
+		return 0, err
+	}
+	if err := sync(cli, c.kindClient.generateAccessAddress(nacos)); err != nil {
+		status.Phase = nacosgroupv1alpha1.ResourcePhaseFailed
+		status.Message = err.Error()
+		if err := c.updateStatus(stored, obj); err != nil {
+			return 0, err
+		}
+		return 0, err
+	}
+	// 只在状态变化时更新同步时间，避免每次resync都触发更新
+	if status.Phase != nacosgroupv1alpha1.ResourcePhaseSynced || status.ObservedGeneration != obj.GetGeneration() {
+		now := metav1.Now()
+		status.LastSyncTime = &now
+	}
+	status.Phase = nacosgroupv1alpha1.ResourcePhaseSynced
+	status.Message = ""
+	status.ObservedGeneration = obj.GetGeneration()
+	return RESOURCE_RESYNC_INTERVAL, c.updateStatus(stored, obj)
+}
+
+// cleanupResource nacos正在运行时删除nacos中的数据，nacos已经被删除时直接移除finalizer。
+// nacos一直无法恢复时，可以通过ANNOTATION_SKIP_CLEANUP跳过清理
+func (c *ResourceClient) cleanupResource(obj nacosResource, nacosName string, status *nacosgroupv1alpha1.NacosResourceStatus,
+	cleanup func(cli *nacosClient.NacosClient, ip string) error) (time.Duration, error) {
+	if !containsString(obj.GetFinalizers(), RESOURCE_CLEANUP_FINALIZER) {
+		return 0, nil
+	}
+	if obj.GetAnnotations()[ANNOTATION_SKIP_CLEANUP] == "true" {
+		c.logger.V(0).Info("skip cleanup", "namespace", obj.GetNamespace(), "name", obj.GetName(), "nacos", nacosName)
+		return c.removeCleanupFinalizer(obj)
+	}
+	nacos := &nacosgroupv1alpha1.Nacos{}
+	err := c.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: nacosName}, nacos)
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	if err == nil && nacos.DeletionTimestamp == nil {
+		stored := obj.DeepCopyObject()
+		status.Phase = nacosgroupv1alpha1.ResourcePhaseDeleting
+		if nacos.Status.Phase != nacosgroupv1alpha1.PhaseRunning {
+			c.logger.V(0).Info("nacos is not running, wait to cleanup", "nacos", nacosName, "phase", nacos.Status.Phase)
+			status.Message = fmt.Sprintf("waiting for nacos %s to be running to clean up, phase is %s; annotate with %s=true to skip",
+				nacosName, nacos.Status.Phase, ANNOTATION_SKIP_CLEANUP)
+			return REQUEUE_INTERVAL, c.updateStatus(stored, obj)
+		}
+		cli, err := c.kindClient.nacosClientFor(nacos)
+		if err != nil {
+			return 0, err
+		}
+		if err := cleanup(cli, c.kindClient.generateAccessAddress(nacos)); err != nil && !nacosClient.IsNotFound(err) {
+			status.Message = fmt.Sprintf("cleanup failed: %s; annotate with %s=true to skip", err.Error(), ANNOTATION_SKIP_CLEANUP)
+			if err := c.updateStatus(stored, obj); err != nil {
+				return 0, err
+			}
+			return 0, err
+		}
+	}
+	return c.removeCleanupFinalizer(obj)
+}
+
+func (c *ResourceClient) removeCleanupFinalizer(obj nacosResource) (time.Duration, error) {
+	obj.SetFinalizers(removeString(obj.GetFinalizers(), RESOURCE_CLEANUP_FINALIZER))
+	return 0, c.client.Update(context.TODO(), obj)
+}
+
+// appliedOr 已经应用到nacos中的标识，旧版本创建的cr没有记录时使用spec中的值
+func appliedOr(applied string, desired string) string {
+	if applied != "" {
+		return applied
+	}
+	return desired
+}
+
+func (c *ResourceClient) updateStatus(stored runtime.Object, obj runtime.Object) error {
+	if reflect.DeepEqual(stored, obj) {
+		return nil
+	}
+	return c.client.Status().Update(context.TODO(), obj)
+}
+
+func toSet(items []string) map[string]bool {
+	set := map[string]bool{}
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// fromSet 按顺序返回，保证status稳定
+func fromSet(set map[string]bool) []string {
+	var items []string
+	for item := range set {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return items
+}
+
+func sortPermissions(set map[nacosgroupv1alpha1.Permission]bool) []nacosgroupv1alpha1.Permission {
+	var items []nacosgroupv1alpha1.Permission
+	for item := range set {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Resource != items[j].Resource {
+			return items[i].Resource < items[j].Resource
+		}
+		return items[i].Action < items[j].Action
+	})
+	return items
+}
@@ -0,0 +1,46 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+)
+
+func TestAppliedOr(t *testing.T) {
+	tests := []struct {
+		applied string
+		desired string
+		want    string
+	}{
+		{"", "dev", "dev"},
+		{"dev", "dev", "dev"},
+		{"dev", "test", "dev"},
+	}
+	for _, tt := range tests {
+		if got := appliedOr(tt.applied, tt.desired); got != tt.want {
+			t.Errorf("appliedOr(%q, %q) = %q, want %q", tt.applied, tt.desired, got, tt.want)
+		}
+	}
+}
+
+func TestSortPermissions(t *testing.T) {
+	set := map[nacosgroupv1alpha1.Permission]bool{
+		{Resource: "dev:*:*", Action: "w"}:   true,
+		{Resource: ":*:*", Action: "r"}:      true,
+		{Resource: "dev:*:*", Action: "r"}:   true,
+		{Resource: "test:*:*", Action: "rw"}: true,
+	}
+	want := []nacosgroupv1alpha1.Permission{
+		{Resource: ":*:*", Action: "r"},
+		{Resource: "dev:*:*", Action: "r"},
+		{Resource: "dev:*:*", Action: "w"},
+		{Resource: "test:*:*", Action: "rw"},
+	}
+	if got := sortPermissions(set); !reflect.DeepEqual(got, want) {
+		t.Errorf("sortPermissions() = %v, want %v", got, want)
+	}
+	if got := fromSet(toSet([]string{"b", "a", "b"})); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("fromSet() = %v", got)
+	}
+}
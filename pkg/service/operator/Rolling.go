@@ -0,0 +1,150 @@
+package operator
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/go-logr/logr"
+	appv1 "k8s.io/api/apps/v1"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	myErrors "nacos.io/nacos-operator/pkg/errors"
+	"nacos.io/nacos-operator/pkg/service/k8s"
+)
+
+// 滚动更新中每一步等待pod恢复的最长时间，超时后标记为Failed
+const ROLLING_STEP_TIMEOUT = time.Minute * 10
+
+type IRollingClient interface {
+	MakeRolling(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error)
+}
+
+type RollingClient struct {
+	k8sService   k8s.Services
+	logger       log.Logger
+	kindClient   *KindClient
+	statusClient *StatusClient
+}
+
+func NewRollingClient(logger log.Logger, k8sService k8s.Services, kindClient *KindClient, statusClient *StatusClient) *RollingClient {
+	return &RollingClient{
+		k8sService:   k8sService,
+		logger:       logger,
+		kindClient:   kindClient,
+		statusClient: statusClient,
+	}
+}
+
+// MakeRolling 按序号从大到小逐个更新pod，上一个pod以新版本ready并重新加入raft集群后才降低partition
+func (c *RollingClient) MakeRolling(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	ss, err := c.k8sService.GetStatefulSet(nacos.Namespace, nacos.Name)
+	if err != nil {
+		return 0, myErrors.NewErr(err)
+	}
+	// statefulset controller还未处理最新的spec，revision不可信
+	if ss.Status.ObservedGeneration < ss.Generation {
+		return REQUEUE_INTERVAL, nil
+	}
+
+	partition := statefulsetPartition(ss)
+	if ss.Status.UpdateRevision == ss.Status.CurrentRevision {
+		if partition == 0 {
+			return 0, nil
+		}
+		// pod模板没有变化，不需要滚动
+		ss.Spec.UpdateStrategy = rollingUpdateStrategy(0)
+		return 0, c.k8sService.UpdateStatefulSet(nacos.Namespace, ss)
+	}
+
+	// 等待上一个更新的pod恢复
+	if partition < *ss.Spec.Replicas {
+		podName := fmt.Sprintf("%s-%d", ss.Name, partition)
+		if reason := c.memberNotReady(nacos, ss, podName); reason != "" {
+			stepTime, err := time.Parse(time.RFC3339, ss.Annotations[ANNOTATION_ROLLING_STEP_TIME])
+			if err != nil {
+				// 没有记录开始时间(例如旧版本operator开始的滚动)，从现在开始计时
+				return REQUEUE_INTERVAL, c.updatePartition(nacos, ss, partition)
+			}
+			if elapsed := time.Since(stepTime); elapsed > ROLLING_STEP_TIMEOUT {
+				return 0, myErrors.New(myErrors.CODE_ROLLING_TIMEOUT, "rolling update is stuck, pod %s is not updated after %s: %s", podName, elapsed.Round(time.Second), reason)
+			}
+			c.logger.V(0).Info("rolling update waiting", "namespace", nacos.Namespace, "name", nacos.Name, "pod", podName, "reason", reason)
+			return REQUEUE_INTERVAL, c.markUpdating(nacos)
+		}
+	}
+	if err := c.markUpdating(nacos); err != nil {
+		return 0, err
+	}
+	// 所有pod都已更新，等待statefulset controller更新currentRevision
+	if partition == 0 {
+		return REQUEUE_INTERVAL, nil
+	}
+	if partition > *ss.Spec.Replicas {
+		partition = *ss.Spec.Replicas
+	}
+
+	partition--
+	if err := c.updatePartition(nacos, ss, partition); err != nil {
+		return 0, err
+	}
+	c.logger.V(0).Info("rolling update", "namespace", nacos.Namespace, "name", nacos.Name, "partition", partition)
+	return REQUEUE_INTERVAL, nil
+}
+
+// markUpdating 滚动过程中phase为Updating
+func (c *RollingClient) markUpdating(nacos *nacosgroupv1alpha1.Nacos) error {
+	if nacos.Status.Phase == nacosgroupv1alpha1.PhaseUpdating {
+		return nil
+	}
+	nacos.Status.Phase = nacosgroupv1alpha1.PhaseUpdating
+	return c.statusClient.UpdateStatus(nacos)
+}
+
+// updatePartition 设置partition，并记录这一步开始的时间
+func (c *RollingClient) updatePartition(nacos *nacosgroupv1alpha1.Nacos, ss *appv1.StatefulSet, partition int32) error {
+	ss.Spec.UpdateStrategy = rollingUpdateStrategy(partition)
+	if ss.Annotations == nil {
+		ss.Annotations = map[string]string{}
+	}
+	ss.Annotations[ANNOTATION_ROLLING_STEP_TIME] = time.Now().Format(time.RFC3339)
+	return c.k8sService.UpdateStatefulSet(nacos.Namespace, ss)
+}
+
+// memberNotReady 返回pod未完成更新的原因，为空表示已经以新版本加入集群
+func (c *RollingClient) memberNotReady(nacos *nacosgroupv1alpha1.Nacos, ss *appv1.StatefulSet, podName string) string {
+	pod, err := c.k8sService.GetPod(nacos.Namespace, podName)
+	if err != nil {
+		return err.Error()
+	}
+	if pod.Labels[appv1.ControllerRevisionHashLabelKey] != ss.Status.UpdateRevision {
+		return "pod is not on update revision"
+	}
+	if !k8s.IsPodReady(pod) {
+		return "pod is not ready: " + k8s.PodNotReadyReason(pod)
+	}
+	if nacos.Spec.Type != TYPE_CLUSTER {
+		return ""
+	}
+
+	cli, err := c.kindClient.nacosClientFor(nacos)
+	if err != nil {
+		return err.Error()
+	}
+	servers, err := c.kindClient.getClusterNodes(cli, nacos, pod.Status.PodIP)
+	if err != nil {
+		return err.Error()
+	}
+	if len(servers.Servers) != int(*ss.Spec.Replicas) {
+		return fmt.Sprintf("member num is %d", len(servers.Servers))
+	}
+	for _, svc := range servers.Servers {
+		if svc.State != "UP" {
+			return fmt.Sprintf("member %s is %s", svc.Address, svc.State)
+		}
+		for group, leader := range raftLeaders(c.kindClient.healthModel(nacos), svc) {
+			if leader == "" {
+				return fmt.Sprintf("member %s has no raft leader of %s", svc.Address, group)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package operator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	myErrors "nacos.io/nacos-operator/pkg/errors"
+	"nacos.io/nacos-operator/pkg/service/k8s"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// fakeRolling 只实现滚动更新用到的接口
+type fakeRolling struct {
+	k8s.Services
+	ss  *appv1.StatefulSet
+	pod *v1.Pod
+}
+
+func (f *fakeRolling) GetStatefulSet(namespace, name string) (*appv1.StatefulSet, error) {
+	return f.ss.DeepCopy(), nil
+}
+
+func (f *fakeRolling) UpdateStatefulSet(namespace string, ss *appv1.StatefulSet) error {
+	f.ss = ss.DeepCopy()
+	return nil
+}
+
+func (f *fakeRolling) GetPod(namespace string, name string) (*v1.Pod, error) {
+	return f.pod.DeepCopy(), nil
+}
+
+func TestMakeRollingTimeout(t *testing.T) {
+	ss := testStatefulset()
+	ss.Name = "nacos"
+	ss.Spec.Replicas = int32Ptr(1)
+	ss.Spec.UpdateStrategy = rollingUpdateStrategy(0)
+	ss.Status.CurrentRevision = "old"
+	ss.Status.UpdateRevision = "new"
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nacos-0", Labels: map[string]string{appv1.ControllerRevisionHashLabelKey: "new"}},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+			Name:  "nacos",
+			State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+		}}},
+	}
+	nacos := &nacosgroupv1alpha1.Nacos{ObjectMeta: metav1.ObjectMeta{Name: "nacos", Namespace: "default"}}
+	nacos.Spec.Type = TYPE_STAND_ALONE
+	nacos.Status.Phase = nacosgroupv1alpha1.PhaseUpdating
+
+	tests := []struct {
+		name     string
+		stepTime string
+		timeout  bool
+	}{
+		{"within deadline", time.Now().Add(-time.Minute).Format(time.RFC3339), false},
+		{"deadline exceeded", time.Now().Add(-ROLLING_STEP_TIMEOUT - time.Minute).Format(time.RFC3339), true},
+		{"no step time", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRolling{ss: ss.DeepCopy(), pod: pod}
+			if tt.stepTime != "" {
+				fake.ss.Annotations = map[string]string{ANNOTATION_ROLLING_STEP_TIME: tt.stepTime}
+			}
+			c := NewRollingClient(ctrl.Log, fake, nil, nil)
+			requeue, err := c.MakeRolling(nacos)
+			if !tt.timeout {
+				if err != nil || requeue == 0 {
+					t.Fatalf("MakeRolling() = %v, %v, want requeue", requeue, err)
+				}
+				if _, err := time.Parse(time.RFC3339, fake.ss.Annotations[ANNOTATION_ROLLING_STEP_TIME]); err != nil {
+					t.Errorf("step time = %q", fake.ss.Annotations[ANNOTATION_ROLLING_STEP_TIME])
+				}
+				return
+			}
+			e, ok := err.(*myErrors.Err)
+			if !ok || e.Code != myErrors.CODE_ROLLING_TIMEOUT {
+				t.Fatalf("MakeRolling() error = %v, want code %d", err, myErrors.CODE_ROLLING_TIMEOUT)
+			}
+			for _, s := range []string{"nacos-0", "ImagePullBackOff"} {
+				if !strings.Contains(e.Msg, s) {
+					t.Errorf("message %q does not mention %s", e.Msg, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/go-logr/logr"
+	appv1 "k8s.io/api/apps/v1"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	myErrors "nacos.io/nacos-operator/pkg/errors"
+	"nacos.io/nacos-operator/pkg/service/k8s"
+)
+
+type IScaleClient interface {
+	MakeScale(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error)
+}
+
+type ScaleClient struct {
+	k8sService   k8s.Services
+	logger       log.Logger
+	kindClient   *KindClient
+	statusClient *StatusClient
+}
+
+func NewScaleClient(logger log.Logger, k8sService k8s.Services, kindClient *KindClient, statusClient *StatusClient) *ScaleClient {
+	return &ScaleClient{
+		k8sService:   k8sService,
+		logger:       logger,
+		kindClient:   kindClient,
+		statusClient: statusClient,
+	}
+}
+
+// MakeScale 集群模式下每次只增加或减少一个成员。
+// cluster.conf中的成员数作为当前步骤的标记：扩容时先写入新成员再增加副本，等待新节点加入集群；
+// 缩容时先移除成员，等待其余节点刷新成员列表后再减少副本
+func (c *ScaleClient) MakeScale(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	if nacos.Spec.Type != TYPE_CLUSTER {
+		nacos.Status.Replicas = *nacos.Spec.Replicas
+		return 0, nil
+	}
+
+	ss, err := c.k8sService.GetStatefulSet(nacos.Namespace, nacos.Name)
+	if err != nil {
+		return 0, myErrors.NewErr(err)
+	}
+	if nacos.Status.Replicas == 0 {
+		// 首次记录集群成员数
+		nacos.Status.Replicas = *ss.Spec.Replicas
+		return REQUEUE_INTERVAL, c.statusClient.UpdateStatus(nacos)
+	}
+	cm, err := c.k8sService.GetConfigMap(nacos.Namespace, c.kindClient.generateClusterConfName(nacos))
+	if err != nil {
+		return 0, myErrors.NewErr(err)
+	}
+
+	current := nacos.Status.Replicas
+	desired := *nacos.Spec.Replicas
+	members := int32(len(strings.Fields(cm.Data[CLUSTER_CONF_KEY])))
+	if desired == current && members == current && *ss.Spec.Replicas == current {
+		return 0, nil
+	}
+
+	switch {
+	case desired > current:
+		return c.scaleOut(nacos, ss, members, current+1)
+	case desired < current:
+		// 每次只移除一个成员，移除前检查剩余成员全部正常，保证每一步都不会失去多数派
+		return c.scaleIn(nacos, ss, members, current-1)
+	default:
+		// 扩容中途撤销，移除未完成加入的成员
+		if err := c.setMembers(nacos, ss, current); err != nil {
+			return 0, err
+		}
+		return REQUEUE_INTERVAL, c.progress(nacos, fmt.Sprintf("reset members to %d", current))
+	}
+}
+
+func (c *ScaleClient) scaleOut(nacos *nacosgroupv1alpha1.Nacos, ss *appv1.StatefulSet, members int32, target int32) (time.Duration, error) {
+	podName := fmt.Sprintf("%s-%d", ss.Name, target-1)
+	if members != target || *ss.Spec.Replicas != target {
+		if err := c.setMembers(nacos, ss, target); err != nil {
+			return 0, err
+		}
+		return REQUEUE_INTERVAL, c.progress(nacos, fmt.Sprintf("scale out %d -> %d: add member %s", target-1, target, podName))
+	}
+
+	if reason := c.membersNotReady(nacos, ss, target); reason != "" {
+		return REQUEUE_INTERVAL, c.progress(nacos, fmt.Sprintf("scale out %d -> %d: wait for %s: %s", target-1, target, podName, reason))
+	}
+	nacos.Status.Replicas = target
+	return REQUEUE_INTERVAL, c.progress(nacos, fmt.Sprintf("scale out %d -> %d: member %s joined", target-1, target, podName))
+}
+
+func (c *ScaleClient) scaleIn(nacos *nacosgroupv1alpha1.Nacos, ss *appv1.StatefulSet, members int32, target int32) (time.Duration, error) {
+	podName := fmt.Sprintf("%s-%d", ss.Name, target)
+	if members != target {
+		// 剩余的节点必须全部正常，否则移除成员后可能失去多数派，等待恢复后再继续
+		if reason := c.membersNotReady(nacos, ss, target+1); reason != "" {
+			return REQUEUE_INTERVAL, c.progress(nacos, fmt.Sprintf("scale in %d -> %d: blocked, can not remove member %s: %s", target+1, target, podName, reason))
+		}
+		if err := c.updateClusterConf(nacos, target); err != nil {
+			return 0, err
+		}
+		return REQUEUE_INTERVAL, c.progress(nacos, fmt.Sprintf("scale in %d -> %d: remove member %s", target+1, target, podName))
+	}
+
+	if reason := c.membersNotReady(nacos, ss, target); reason != "" {
+		return REQUEUE_INTERVAL, c.progress(nacos, fmt.Sprintf("scale in %d -> %d: wait for member list refresh: %s", target+1, target, reason))
+	}
+	ss.Spec.Replicas = &target
+	if err := c.k8sService.UpdateStatefulSet(nacos.Namespace, ss); err != nil {
+		return 0, err
+	}
+	nacos.Status.Replicas = target
+	return REQUEUE_INTERVAL, c.progress(nacos, fmt.Sprintf("scale in %d -> %d: member %s removed", target+1, target, podName))
+}
+
+// 同时调整cluster.conf和statefulset副本数
+func (c *ScaleClient) setMembers(nacos *nacosgroupv1alpha1.Nacos, ss *appv1.StatefulSet, n int32) error {
+	if err := c.updateClusterConf(nacos, n); err != nil {
+		return err
+	}
+	if *ss.Spec.Replicas == n {
+		return nil
+	}
+	ss.Spec.Replicas = &n
+	return c.k8sService.UpdateStatefulSet(nacos.Namespace, ss)
+}
+
+func (c *ScaleClient) updateClusterConf(nacos *nacosgroupv1alpha1.Nacos, n int32) error {
+	cm, err := c.kindClient.buildClusterConfigMap(nacos, n)
+	if err != nil {
+		return err
+	}
+	return c.k8sService.CreateOrUpdateConfigMap(nacos.Namespace, cm)
+}
+
+// membersNotReady 检查前n个pod都已ready，并且在第一个节点看来集群正好有n个UP的成员，为空表示正常
+func (c *ScaleClient) membersNotReady(nacos *nacosgroupv1alpha1.Nacos, ss *appv1.StatefulSet, n int32) string {
+	ip := ""
+	for i := int32(0); i < n; i++ {
+		pod, err := c.k8sService.GetPod(nacos.Namespace, fmt.Sprintf("%s-%d", ss.Name, i))
+		if err != nil {
+			return err.Error()
+		}
+		if !k8s.IsPodReady(pod) {
+			return fmt.Sprintf("pod %s is not ready: %s", pod.Name, k8s.PodNotReadyReason(pod))
+		}
+		if ip == "" {
+			ip = pod.Status.PodIP
+		}
+	}
+
+	cli, err := c.kindClient.nacosClientFor(nacos)
+	if err != nil {
+		return err.Error()
+	}
+	servers, err := c.kindClient.getClusterNodes(cli, nacos, ip)
+	if err != nil {
+		return err.Error()
+	}
+	if len(servers.Servers) != int(n) {
+		return fmt.Sprintf("member num is %d", len(servers.Servers))
+	}
+	for _, svc := range servers.Servers {
+		if svc.State != "UP" {
+			return fmt.Sprintf("member %s is %s", svc.Address, svc.State)
+		}
+	}
+	return ""
+}
+
+// progress 在status中记录扩缩容进度，内容没有变化时不重复更新
+func (c *ScaleClient) progress(nacos *nacosgroupv1alpha1.Nacos, msg string) error {
+	if size := len(nacos.Status.Event); size > 0 && nacos.Status.Phase == nacosgroupv1alpha1.PhaseScale {
+		last := nacos.Status.Event[size-1]
+		if last.Code == myErrors.CODE_SCALE && last.Message == msg {
+			return nil
+		}
+	}
+	c.logger.V(0).Info("scale", "namespace", nacos.Namespace, "name", nacos.Name, "progress", msg)
+	nacos.Status.Phase = nacosgroupv1alpha1.PhaseScale
+	c.statusClient.updateLastEvent(nacos, myErrors.CODE_SCALE, msg, true)
+	return c.statusClient.UpdateStatus(nacos)
+}
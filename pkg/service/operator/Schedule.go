@@ -0,0 +1,269 @@
+package operator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// 定时备份创建的备份上记录所属定时备份的label
+const LABEL_BACKUP_SCHEDULE = "nacos.io/backup-schedule"
+
+// 默认保留的失败备份个数
+const BACKUP_KEEP_FAILED = 3
+
+// 备份job名称中最长的后缀，见BackupClient
+const BACKUP_JOB_SUFFIX_MAX = "-backup"
+
+// 错过多次调度时最多向后追赶的次数
+const SCHEDULE_MAX_MISSED = 1000
+
+type IScheduleClient interface {
+	MakeBackupSchedule(schedule *nacosgroupv1alpha1.NacosBackupSchedule) (time.Duration, error)
+}
+
+type ScheduleClient struct {
+	logger log.Logger
+	client client.Client
+}
+
+func NewScheduleClient(logger log.Logger, client client.Client) *ScheduleClient {
+	return &ScheduleClient{
+		logger: logger,
+		client: client,
+	}
+}
+
+// MakeBackupSchedule 按照cron表达式创建NacosBackup，并按照保留策略清理旧的备份
+func (c *ScheduleClient) MakeBackupSchedule(schedule *nacosgroupv1alpha1.NacosBackupSchedule) (time.Duration, error) {
+	stored := schedule.Status.DeepCopy()
+	defer func() {
+		if !reflect.DeepEqual(stored, &schedule.Status) {
+			if err := c.client.Status().Update(context.TODO(), schedule); err != nil {
+				c.logger.V(0).Info("update schedule status failed", "namespace", schedule.Namespace, "name", schedule.Name, "err", err.Error())
+			}
+		}
+	}()
+
+	// 名称作为备份的label值，不能超过label的长度限制
+	if len(schedule.Name) > validation.LabelValueMaxLength {
+		schedule.Status.Message = fmt.Sprintf("name must be no more than %d characters", validation.LabelValueMaxLength)
+		return 0, nil
+	}
+	sched, err := cron.ParseStandard(schedule.Spec.Schedule)
+	if err != nil {
+		// 表达式错误只能等待用户修改
+		schedule.Status.Message = fmt.Sprintf("invalid schedule %q: %v", schedule.Spec.Schedule, err)
+		return 0, nil
+	}
+	schedule.Status.Message = ""
+
+	backups := &nacosgroupv1alpha1.NacosBackupList{}
+	if err := c.client.List(context.TODO(), backups, client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{LABEL_BACKUP_SCHEDULE: schedule.Name}); err != nil {
+		return 0, err
+	}
+	running := false
+	for _, item := range backups.Items {
+		switch item.Status.Phase {
+		case nacosgroupv1alpha1.BackupPhaseSucceeded:
+			if item.Status.CompletionTime != nil && (schedule.Status.LastSuccessfulTime == nil ||
+				item.Status.CompletionTime.After(schedule.Status.LastSuccessfulTime.Time)) {
+				schedule.Status.LastSuccessfulTime = item.Status.CompletionTime
+			}
+		case nacosgroupv1alpha1.BackupPhaseFailed:
+		default:
+			if item.DeletionTimestamp == nil {
+				running = true
+			}
+		}
+	}
+	if err := c.prune(schedule, backups.Items); err != nil {
+		return 0, err
+	}
+	if schedule.Spec.Suspend {
+		return 0, nil
+	}
+
+	now := time.Now()
+	last := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		last = schedule.Status.LastScheduleTime.Time
+	}
+	next := sched.Next(last)
+	if next.After(now) {
+		return next.Sub(now), nil
+	}
+	// 错过多次调度时只补最近的一次
+	for i := 0; i < SCHEDULE_MAX_MISSED; i++ {
+		t := sched.Next(next)
+		if t.After(now) {
+			break
+		}
+		next = t
+	}
+	// 不并发备份，等待上一次备份结束
+	if running {
+		return REQUEUE_INTERVAL, nil
+	}
+
+	nacosBackup := c.buildBackup(schedule, next)
+	if err := c.client.Create(context.TODO(), nacosBackup); err != nil && !errors.IsAlreadyExists(err) {
+		return 0, err
+	}
+	c.logger.V(0).Info("create scheduled backup", "namespace", schedule.Namespace, "name", nacosBackup.Name)
+	schedule.Status.LastScheduleTime = &metav1.Time{Time: next}
+	schedule.Status.LastBackup = nacosBackup.Name
+	return sched.Next(now).Sub(now), nil
+}
+
+// buildBackup 定时备份不设置ownerReference，删除定时备份时保留已有的备份
+func (c *ScheduleClient) buildBackup(schedule *nacosgroupv1alpha1.NacosBackupSchedule, t time.Time) *nacosgroupv1alpha1.NacosBackup {
+	return &nacosgroupv1alpha1.NacosBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       scheduledBackupName(schedule.Name, t),
+			Namespace:  schedule.Namespace,
+			Labels:     map[string]string{LABEL_BACKUP_SCHEDULE: schedule.Name},
+			Finalizers: []string{BACKUP_CLEANUP_FINALIZER},
+		},
+		Spec: *schedule.Spec.Template.DeepCopy(),
+	}
+}
+
+// scheduledBackupName 备份名称为<schedule>-YYYYMMDD-HHMM。备份job的名称会再加上-backup或-delete，
+// 并作为job-name label，名称过长时截断并加上hash，保证不超过label的长度限制
+func scheduledBackupName(schedule string, t time.Time) string {
+	suffix := "-" + t.UTC().Format("20060102-1504")
+	maxPrefix := validation.LabelValueMaxLength - len(BACKUP_JOB_SUFFIX_MAX) - len(suffix)
+	if len(schedule) > maxPrefix {
+		sum := sha256.Sum256([]byte(schedule))
+		hash := hex.EncodeToString(sum[:])[:8]
+		schedule = strings.TrimRight(schedule[:maxPrefix-len(hash)-1], "-.") + "-" + hash
+	}
+	return schedule + suffix
+}
+
+// prune 删除不满足保留策略的备份，备份文件由备份的finalizer删除
+func (c *ScheduleClient) prune(schedule *nacosgroupv1alpha1.NacosBackupSchedule, items []nacosgroupv1alpha1.NacosBackup) error {
+	retention := schedule.Spec.Retention
+	if retention == nil {
+		return nil
+	}
+	var succeeded, failed []nacosgroupv1alpha1.NacosBackup
+	for _, item := range items {
+		if item.DeletionTimestamp != nil {
+			continue
+		}
+		switch item.Status.Phase {
+		case nacosgroupv1alpha1.BackupPhaseSucceeded:
+			succeeded = append(succeeded, item)
+		case nacosgroupv1alpha1.BackupPhaseFailed:
+			failed = append(failed, item)
+		}
+	}
+
+	keep := retainBackups(succeeded, retention, time.Now())
+	keepFailed := BACKUP_KEEP_FAILED
+	if retention.KeepFailed != nil {
+		keepFailed = int(*retention.KeepFailed)
+	}
+	sortBackups(failed)
+	for i := range failed {
+		if i < keepFailed {
+			keep[failed[i].Name] = true
+		}
+	}
+
+	for i := range succeeded {
+		if err := c.deleteBackup(&succeeded[i], keep); err != nil {
+			return err
+		}
+	}
+	for i := range failed {
+		if err := c.deleteBackup(&failed[i], keep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ScheduleClient) deleteBackup(nacosBackup *nacosgroupv1alpha1.NacosBackup, keep map[string]bool) error {
+	if keep[nacosBackup.Name] {
+		return nil
+	}
+	c.logger.V(0).Info("prune backup", "namespace", nacosBackup.Namespace, "name", nacosBackup.Name, "location", nacosBackup.Status.Location)
+	if err := c.client.Delete(context.TODO(), nacosBackup); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// retainBackups 返回需要保留的成功备份，满足任意一条规则即保留
+func retainBackups(backups []nacosgroupv1alpha1.NacosBackup, retention *nacosgroupv1alpha1.BackupRetention, now time.Time) map[string]bool {
+	keep := map[string]bool{}
+	sortBackups(backups)
+	if retention.KeepLast != nil {
+		for i := 0; i < len(backups) && i < int(*retention.KeepLast); i++ {
+			keep[backups[i].Name] = true
+		}
+	}
+	if retention.KeepDaily != nil {
+		since := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-int(*retention.KeepDaily))
+		keepPeriod(backups, keep, since, func(t time.Time) string {
+			return t.Format("2006-01-02")
+		})
+	}
+	if retention.KeepWeekly != nil {
+		// 从本周一开始向前数N周
+		today := now.UTC().Truncate(24 * time.Hour)
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		since := monday.AddDate(0, 0, -7*(int(*retention.KeepWeekly)-1))
+		keepPeriod(backups, keep, since, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		})
+	}
+	return keep
+}
+
+// keepPeriod 保留since之后每个周期最新的一个备份，backups按时间倒序
+func keepPeriod(backups []nacosgroupv1alpha1.NacosBackup, keep map[string]bool, since time.Time, period func(t time.Time) string) {
+	seen := map[string]bool{}
+	for _, item := range backups {
+		t := backupTime(&item).UTC()
+		if t.Before(since) {
+			break
+		}
+		if p := period(t); !seen[p] {
+			seen[p] = true
+			keep[item.Name] = true
+		}
+	}
+}
+
+// sortBackups 按照备份时间倒序排列
+func sortBackups(backups []nacosgroupv1alpha1.NacosBackup) {
+	sort.Slice(backups, func(i, j int) bool {
+		return backupTime(&backups[i]).After(backupTime(&backups[j]))
+	})
+}
+
+func backupTime(nacosBackup *nacosgroupv1alpha1.NacosBackup) time.Time {
+	if nacosBackup.Status.CompletionTime != nil {
+		return nacosBackup.Status.CompletionTime.Time
+	}
+	return nacosBackup.CreationTimestamp.Time
+}
@@ -0,0 +1,94 @@
+package operator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// testBackups 从2021-02-25到2021-03-17每天01:00和13:00各一个备份
+func testBackups() []nacosgroupv1alpha1.NacosBackup {
+	var backups []nacosgroupv1alpha1.NacosBackup
+	start := time.Date(2021, 2, 25, 1, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 3, 17, 1, 0, 0, 0, time.UTC)
+	for t := start; !t.After(end); t = t.Add(12 * time.Hour) {
+		completion := metav1.NewTime(t)
+		backups = append(backups, nacosgroupv1alpha1.NacosBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: t.Format("0102-1504")},
+			Status:     nacosgroupv1alpha1.NacosBackupStatus{CompletionTime: &completion},
+		})
+	}
+	return backups
+}
+
+func TestRetainBackups(t *testing.T) {
+	// 2021-03-17是周三
+	now := time.Date(2021, 3, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		retention nacosgroupv1alpha1.BackupRetention
+		want      []string
+	}{
+		{"empty", nacosgroupv1alpha1.BackupRetention{}, nil},
+		{"keepLast", nacosgroupv1alpha1.BackupRetention{KeepLast: int32Ptr(3)}, []string{"0317-0100", "0316-1300", "0316-0100"}},
+		{"keepDaily", nacosgroupv1alpha1.BackupRetention{KeepDaily: int32Ptr(2)}, []string{"0317-0100", "0316-1300"}},
+		{"keepDaily zero", nacosgroupv1alpha1.BackupRetention{KeepDaily: int32Ptr(0)}, nil},
+		{"keepWeekly", nacosgroupv1alpha1.BackupRetention{KeepWeekly: int32Ptr(2)}, []string{"0317-0100", "0314-1300"}},
+		{"keepWeekly longer than history", nacosgroupv1alpha1.BackupRetention{KeepWeekly: int32Ptr(10)},
+			[]string{"0317-0100", "0314-1300", "0307-1300", "0228-1300"}},
+		{"union", nacosgroupv1alpha1.BackupRetention{KeepLast: int32Ptr(1), KeepDaily: int32Ptr(3), KeepWeekly: int32Ptr(2)},
+			[]string{"0317-0100", "0316-1300", "0315-1300", "0314-1300"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep := retainBackups(testBackups(), &tt.retention, now)
+			want := map[string]bool{}
+			for _, name := range tt.want {
+				want[name] = true
+			}
+			if !reflect.DeepEqual(keep, want) {
+				t.Errorf("keep = %v, want %v", fromSet(keep), tt.want)
+			}
+		})
+	}
+}
+
+func TestSortBackups(t *testing.T) {
+	backups := testBackups()
+	backups[0].Status.CompletionTime = nil
+	backups[0].CreationTimestamp = metav1.NewTime(time.Date(2021, 3, 18, 0, 0, 0, 0, time.UTC))
+	sortBackups(backups)
+	if backups[0].Name != "0225-0100" || backups[1].Name != "0317-0100" || backups[len(backups)-1].Name != "0225-1300" {
+		t.Errorf("order = %s, %s ... %s", backups[0].Name, backups[1].Name, backups[len(backups)-1].Name)
+	}
+}
+
+func TestScheduledBackupName(t *testing.T) {
+	now := time.Date(2021, 3, 14, 9, 40, 0, 0, time.UTC)
+	if name := scheduledBackupName("daily", now); name != "daily-20210314-0940" {
+		t.Errorf("name = %s", name)
+	}
+	long := strings.Repeat("a", 50)
+	name := scheduledBackupName(long, now)
+	if len(name)+len(BACKUP_JOB_SUFFIX_MAX) > validation.LabelValueMaxLength {
+		t.Errorf("job name %s%s is longer than %d", name, BACKUP_JOB_SUFFIX_MAX, validation.LabelValueMaxLength)
+	}
+	if !strings.HasSuffix(name, "-20210314-0940") {
+		t.Errorf("name = %s", name)
+	}
+	if other := scheduledBackupName(long+"b", now); other == name {
+		t.Errorf("names of different schedules collide: %s", name)
+	}
+	if errs := validation.IsDNS1123Label(name + BACKUP_JOB_SUFFIX_MAX); len(errs) > 0 {
+		t.Errorf("job name is invalid: %v", errs)
+	}
+}
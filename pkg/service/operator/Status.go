@@ -4,7 +4,9 @@ import (
 	"context"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	log "github.com/go-logr/logr"
@@ -17,35 +19,53 @@ type IStatusClient interface {
 }
 
 type StatusClient struct {
-	logger log.Logger
-	client client.Client
+	logger   log.Logger
+	client   client.Client
+	recorder record.EventRecorder
 }
 
-func NewStatusClient(logger log.Logger, k8sService k8s.Services, client client.Client) *StatusClient {
+func NewStatusClient(logger log.Logger, k8sService k8s.Services, client client.Client, recorder record.EventRecorder) *StatusClient {
 	return &StatusClient{
-		client: client,
-		logger: logger,
+		client:   client,
+		logger:   logger,
+		recorder: recorder,
 	}
 }
 
 // 更新状态
-func (c *StatusClient) UpdateStatusRunning(nacos *nacosgroupv1alpha1.Nacos) {
-	c.updateLastEvent(nacos, 200, "", true)
+func (c *StatusClient) UpdateStatusRunning(nacos *nacosgroupv1alpha1.Nacos) error {
+	c.updateLastEvent(nacos, myErrors.CODE_NORMAL, "", true)
+	if nacos.Status.Phase != nacosgroupv1alpha1.PhaseRunning {
+		c.recorder.Eventf(nacos, corev1.EventTypeNormal, EVENT_REASON_RUNNING, "nacos is running, phase was %s", nacos.Status.Phase)
+	}
 	nacos.Status.Phase = nacosgroupv1alpha1.PhaseRunning
-	// TODO
-	myErrors.EnsureNormal(c.client.Status().Update(context.TODO(), nacos))
+	setCondition(nacos, nacosgroupv1alpha1.ConditionReady, true, string(nacosgroupv1alpha1.PhaseRunning), "")
+	syncConditions(nacos)
+	// 只有当前spec完整处理完成后才推进observedGeneration
+	nacos.Status.ObservedGeneration = nacos.Generation
+	return c.client.Status().Update(context.TODO(), nacos)
 }
 
 // 更新状态
-func (c *StatusClient) UpdateStatus(nacos *nacosgroupv1alpha1.Nacos) {
-	// TODO
-	myErrors.EnsureNormal(c.client.Status().Update(context.TODO(), nacos))
+func (c *StatusClient) UpdateStatus(nacos *nacosgroupv1alpha1.Nacos) error {
+	syncConditions(nacos)
+	return c.client.Status().Update(context.TODO(), nacos)
+}
+
+// RecordEvent 只追加status.event，不修改phase、condition和observedGeneration，用于其他cr记录结果
+func (c *StatusClient) RecordEvent(nacos *nacosgroupv1alpha1.Nacos, code int, msg string, status bool) error {
+	c.updateLastEvent(nacos, code, msg, status)
+	return c.client.Status().Update(context.TODO(), nacos)
 }
 
 func (c *StatusClient) UpdateExceptionStatus(nacos *nacosgroupv1alpha1.Nacos, err *myErrors.Err) {
 	c.updateLastEvent(nacos, err.Code, err.Msg, false)
+	c.recorder.Eventf(nacos, corev1.EventTypeWarning, EVENT_REASON_FAILED, "code %d: %s", err.Code, err.Msg)
 	// 设置为异常状态
 	nacos.Status.Phase = nacosgroupv1alpha1.PhaseFailed
+	setCondition(nacos, nacosgroupv1alpha1.ConditionReady, false, myErrors.Reason(err.Code), err.Msg)
+	setCondition(nacos, nacosgroupv1alpha1.ConditionDegraded, true, myErrors.Reason(err.Code), err.Msg)
+	syncConditions(nacos)
 	e := c.client.Status().Update(context.TODO(), nacos)
 	if e != nil {
 		c.logger.V(-1).Info(e.Error())
@@ -53,6 +73,47 @@ func (c *StatusClient) UpdateExceptionStatus(nacos *nacosgroupv1alpha1.Nacos, er
 
 }
 
+// setCondition 设置condition，observedGeneration为当前cr的generation
+func setCondition(nacos *nacosgroupv1alpha1.Nacos, conditionType string, status bool, reason string, message string) {
+	conditionStatus := metav1.ConditionFalse
+	if status {
+		conditionStatus = metav1.ConditionTrue
+	}
+	nacos.Status.SetCondition(nacosgroupv1alpha1.Condition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		ObservedGeneration: nacos.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// syncConditions 保存状态前根据phase设置Progressing，并清理旧版本按pod记录的condition
+func syncConditions(nacos *nacosgroupv1alpha1.Nacos) {
+	conditions := []nacosgroupv1alpha1.Condition{}
+	for _, condition := range nacos.Status.Conditions {
+		switch condition.Type {
+		case nacosgroupv1alpha1.ConditionReady, nacosgroupv1alpha1.ConditionAvailable, nacosgroupv1alpha1.ConditionProgressing,
+			nacosgroupv1alpha1.ConditionDegraded, nacosgroupv1alpha1.ConditionDatabaseInitialized, nacosgroupv1alpha1.ConditionSpecDrifted:
+			conditions = append(conditions, condition)
+		}
+	}
+	nacos.Status.Conditions = conditions
+
+	phase := nacos.Status.Phase
+	switch phase {
+	case nacosgroupv1alpha1.PhaseCreating, nacosgroupv1alpha1.PhaseUpdating, nacosgroupv1alpha1.PhaseScale:
+		message := ""
+		if size := len(nacos.Status.Event); phase == nacosgroupv1alpha1.PhaseScale && size > 0 {
+			message = nacos.Status.Event[size-1].Message
+		}
+		setCondition(nacos, nacosgroupv1alpha1.ConditionProgressing, true, string(phase), message)
+		setCondition(nacos, nacosgroupv1alpha1.ConditionReady, false, string(phase), message)
+	default:
+		setCondition(nacos, nacosgroupv1alpha1.ConditionProgressing, false, string(phase), "")
+	}
+}
+
 const EVENT_MAX_SIZE = 10
 
 func (c *StatusClient) updateLastEvent(nacos *nacosgroupv1alpha1.Nacos, code int, msg string, status bool) {
@@ -0,0 +1,37 @@
+package operator
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+)
+
+func TestSyncConditions(t *testing.T) {
+	nacos := &nacosgroupv1alpha1.Nacos{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+	nacos.Status.ObservedGeneration = 1
+	nacos.Status.Phase = nacosgroupv1alpha1.PhaseUpdating
+	nacos.Status.Conditions = []nacosgroupv1alpha1.Condition{
+		{Type: "nacos-0", Status: metav1.ConditionTrue},
+		{Type: nacosgroupv1alpha1.ConditionAvailable, Status: metav1.ConditionTrue},
+	}
+
+	syncConditions(nacos)
+	if nacos.Status.ObservedGeneration != 1 {
+		t.Errorf("observedGeneration = %d, want 1 until the cluster is running", nacos.Status.ObservedGeneration)
+	}
+	if nacos.Status.FindCondition("nacos-0") != nil {
+		t.Errorf("per-pod condition is not pruned")
+	}
+	if nacos.Status.FindCondition(nacosgroupv1alpha1.ConditionAvailable) == nil {
+		t.Errorf("available condition is pruned")
+	}
+	progressing := nacos.Status.FindCondition(nacosgroupv1alpha1.ConditionProgressing)
+	if progressing == nil || progressing.Status != metav1.ConditionTrue || progressing.ObservedGeneration != 2 {
+		t.Errorf("progressing = %+v", progressing)
+	}
+	ready := nacos.Status.FindCondition(nacosgroupv1alpha1.ConditionReady)
+	if ready == nil || ready.Status != metav1.ConditionFalse {
+		t.Errorf("ready = %+v", ready)
+	}
+}
@@ -0,0 +1,285 @@
+package operator
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	myErrors "nacos.io/nacos-operator/pkg/errors"
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// 证书在容器中的挂载目录
+const TLS_VOLUME_NAME = "tls"
+const TLS_MOUNT_PATH = "/home/nacos/tls"
+
+// 证书secret中的key，keystore用于nacos的server.ssl，ca用于operator和备份job校验服务端证书
+const TLS_KEYSTORE_KEY = "keystore.p12"
+const TLS_CA_KEY = "ca.crt"
+
+// operator生成的keystore密码secret中的key
+const TLS_KEYSTORE_PASSWORD_KEY = "password"
+
+// keystore密码长度
+const TLS_KEYSTORE_PASSWORD_LENGTH = 16
+
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// 证书所在的secret，使用cert-manager时为<name>-tls
+func (e *KindClient) generateTLSSecretName(nacos *nacosgroupv1alpha1.Nacos) string {
+	if nacos.Spec.TLS.SecretName != "" {
+		return nacos.Spec.TLS.SecretName
+	}
+	return nacos.Name + "-tls"
+}
+
+func (e *KindClient) generateKeystoreSecretName(nacos *nacosgroupv1alpha1.Nacos) string {
+	return nacos.Name + "-tls-keystore"
+}
+
+func (e *KindClient) tlsSelector(nacos *nacosgroupv1alpha1.Nacos, key string) *v1.SecretKeySelector {
+	return &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: e.generateTLSSecretName(nacos)},
+		Key:                  key,
+	}
+}
+
+// keystore密码的来源，优先使用cr中指定的secret
+func (e *KindClient) keystorePasswordSelector(nacos *nacosgroupv1alpha1.Nacos) *v1.SecretKeySelector {
+	if nacos.Spec.TLS.KeystorePasswordSecretRef != nil {
+		return nacos.Spec.TLS.KeystorePasswordSecretRef
+	}
+	return &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: e.generateKeystoreSecretName(nacos)},
+		Key:                  TLS_KEYSTORE_PASSWORD_KEY,
+	}
+}
+
+// tlsSelectors 证书续期后secret内容变化，需要滚动更新pod加载新的证书
+func (e *KindClient) tlsSelectors(nacos *nacosgroupv1alpha1.Nacos) []*v1.SecretKeySelector {
+	if !nacos.Spec.TLS.Enabled {
+		return nil
+	}
+	return []*v1.SecretKeySelector{
+		e.tlsSelector(nacos, TLS_KEYSTORE_KEY),
+		e.tlsSelector(nacos, TLS_CA_KEY),
+		e.keystorePasswordSelector(nacos),
+	}
+}
+
+// generateTLSDnsNames 证书中包含所有service的域名，集群成员之间通过headless service的pod域名访问
+func (e *KindClient) generateTLSDnsNames(nacos *nacosgroupv1alpha1.Nacos) []string {
+	svcs := []string{e.generateName(nacos)}
+	if nacos.Spec.Type == TYPE_CLUSTER {
+		svcs = []string{e.generateClientSvcName(nacos), e.generateHeadlessSvcName(nacos)}
+	}
+	names := []string{}
+	for _, svc := range svcs {
+		names = append(names,
+			svc,
+			fmt.Sprintf("%s.%s", svc, nacos.Namespace),
+			fmt.Sprintf("%s.%s.svc", svc, nacos.Namespace),
+			fmt.Sprintf("%s.%s.svc.cluster.local", svc, nacos.Namespace),
+		)
+	}
+	if nacos.Spec.Type == TYPE_CLUSTER {
+		names = append(names, fmt.Sprintf("*.%s.%s.svc.cluster.local", e.generateHeadlessSvcName(nacos), nacos.Namespace))
+	}
+	return names
+}
+
+// EnsureTLS 配置了issuerRef时生成keystore密码并维护cert-manager的Certificate，
+// 等待cert-manager签发证书后再创建statefulset
+func (e *KindClient) EnsureTLS(nacos *nacosgroupv1alpha1.Nacos) error {
+	tls := nacos.Spec.TLS
+	var desired *unstructured.Unstructured
+	if tls.Enabled && tls.IssuerRef != nil {
+		if err := e.ensureKeystoreSecret(nacos); err != nil {
+			return err
+		}
+		var err error
+		if desired, err = e.buildCertificate(nacos); err != nil {
+			return err
+		}
+	}
+	err := e.ensureUnstructured(nacos, certificateGVK, desired)
+	if meta.IsNoMatchError(err) {
+		if desired == nil {
+			return nil
+		}
+		return myErrors.New(myErrors.CODE_PARAMETER_ERROR, "cert-manager crd not found, spec.tls.issuerRef requires cert-manager")
+	}
+	if err != nil {
+		return err
+	}
+	if !tls.Enabled {
+		return nil
+	}
+	secret, err := e.k8sService.GetSecret(nacos.Namespace, e.generateTLSSecretName(nacos))
+	if err != nil {
+		return myErrors.New(myErrors.CODE_TLS_NOT_READY, "certificate secret %s not ready: %s", e.generateTLSSecretName(nacos), err.Error())
+	}
+	for _, key := range []string{TLS_KEYSTORE_KEY, TLS_CA_KEY} {
+		if len(secret.Data[key]) == 0 {
+			return myErrors.New(myErrors.CODE_TLS_NOT_READY, "certificate secret %s has no key %s", secret.Name, key)
+		}
+	}
+	return nil
+}
+
+func (e *KindClient) ensureKeystoreSecret(nacos *nacosgroupv1alpha1.Nacos) error {
+	if nacos.Spec.TLS.KeystorePasswordSecretRef != nil {
+		return nil
+	}
+	if _, err := e.k8sService.GetSecret(nacos.Namespace, e.generateKeystoreSecretName(nacos)); err == nil {
+		return nil
+	}
+	password, err := generatePassword(TLS_KEYSTORE_PASSWORD_LENGTH)
+	if err != nil {
+		return err
+	}
+	labels := e.generateLabels(nacos.Name, NACOS)
+	labels = e.MergeLabels(nacos.Labels, labels)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      e.generateKeystoreSecretName(nacos),
+			Namespace: nacos.Namespace,
+			Labels:    labels,
+		},
+		Type:       v1.SecretTypeOpaque,
+		StringData: map[string]string{TLS_KEYSTORE_PASSWORD_KEY: password},
+	}
+	if err := controllerutil.SetControllerReference(nacos, secret, e.scheme); err != nil {
+		return err
+	}
+	return e.k8sService.CreateIfNotExistsSecret(nacos.Namespace, secret)
+}
+
+func (e *KindClient) buildCertificate(nacos *nacosgroupv1alpha1.Nacos) (*unstructured.Unstructured, error) {
+	labels := e.generateLabels(nacos.Name, NACOS)
+	labels = e.MergeLabels(nacos.Labels, labels)
+
+	dnsNames := []interface{}{}
+	for _, name := range e.generateTLSDnsNames(nacos) {
+		dnsNames = append(dnsNames, name)
+	}
+	issuer := nacos.Spec.TLS.IssuerRef
+	password := e.keystorePasswordSelector(nacos)
+	spec := map[string]interface{}{
+		"secretName": e.generateTLSSecretName(nacos),
+		"commonName": e.generateAccessAddress(nacos),
+		"dnsNames":   dnsNames,
+		"issuerRef": map[string]interface{}{
+			"name":  issuer.Name,
+			"kind":  issuer.Kind,
+			"group": issuer.Group,
+		},
+		"keystores": map[string]interface{}{
+			"pkcs12": map[string]interface{}{
+				"create": true,
+				"passwordSecretRef": map[string]interface{}{
+					"name": password.Name,
+					"key":  password.Key,
+				},
+			},
+		},
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(certificateGVK)
+	obj.SetName(e.generateName(nacos))
+	obj.SetNamespace(nacos.Namespace)
+	obj.SetLabels(labels)
+	if err := controllerutil.SetControllerReference(nacos, obj, e.scheme); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// tlsEnv 通过spring的环境变量开启server.ssl，用户在spec.env中配置时以用户为准
+func (e *KindClient) tlsEnv(nacos *nacosgroupv1alpha1.Nacos) []v1.EnvVar {
+	if !nacos.Spec.TLS.Enabled {
+		return nil
+	}
+	items := []v1.EnvVar{
+		{Name: "SERVER_SSL_ENABLED", Value: "true"},
+		{Name: "SERVER_SSL_KEY_STORE", Value: TLS_MOUNT_PATH + "/" + TLS_KEYSTORE_KEY},
+		{Name: "SERVER_SSL_KEY_STORE_TYPE", Value: "PKCS12"},
+		{Name: "SERVER_SSL_KEY_STORE_PASSWORD", ValueFrom: &v1.EnvVarSource{SecretKeyRef: e.keystorePasswordSelector(nacos)}},
+	}
+	env := []v1.EnvVar{}
+	for _, item := range items {
+		if !containsEnv(nacos.Spec.Env, item.Name) {
+			env = append(env, item)
+		}
+	}
+	return env
+}
+
+// applyTLS 挂载证书，集群成员之间的http请求使用ca校验证书，并把访问nacos端口的http probe(包括默认probe)切换为https
+func (e *KindClient) applyTLS(nacos *nacosgroupv1alpha1.Nacos, podSpec *v1.PodSpec) {
+	if !nacos.Spec.TLS.Enabled {
+		return
+	}
+	podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
+		Name: TLS_VOLUME_NAME,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: e.generateTLSSecretName(nacos)},
+		},
+	})
+	container := &podSpec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+		Name:      TLS_VOLUME_NAME,
+		MountPath: TLS_MOUNT_PATH,
+		ReadOnly:  true,
+	})
+	appendJavaOpt(container, fmt.Sprintf("-Dtls.enable=true -Dtls.client.trustCertPath=%s/%s", TLS_MOUNT_PATH, TLS_CA_KEY))
+	container.LivenessProbe = httpsProbe(nacos, container.LivenessProbe)
+	container.ReadinessProbe = httpsProbe(nacos, container.ReadinessProbe)
+	container.StartupProbe = httpsProbe(nacos, container.StartupProbe)
+}
+
+func httpsProbe(nacos *nacosgroupv1alpha1.Nacos, probe *v1.Probe) *v1.Probe {
+	if probe == nil || probe.HTTPGet == nil {
+		return probe
+	}
+	port := probe.HTTPGet.Port
+	if !(port.Type == intstr.Int && port.IntVal == nacos.ServerPort()) && !(port.Type == intstr.String && port.StrVal == "client") {
+		return probe
+	}
+	probe = probe.DeepCopy()
+	probe.HTTPGet.Scheme = v1.URISchemeHTTPS
+	return probe
+}
+
+// clientAppProtocol 开启tls后标记client端口为https
+func clientAppProtocol(nacos *nacosgroupv1alpha1.Nacos) *string {
+	if !nacos.Spec.TLS.Enabled {
+		return nil
+	}
+	protocol := "https"
+	return &protocol
+}
+
+// baseNacosClient 使用实例的端口和访问路径，不带账号；开启tls时使用集群ca校验服务端证书，
+// operator通过pod ip访问，使用access service的域名校验
+func (e *KindClient) baseNacosClient(nacos *nacosgroupv1alpha1.Nacos) (*nacosClient.NacosClient, error) {
+	base := e.nacosClient.WithEndpoint(nacos.ServerPort(), nacos.ServerContextPath())
+	if !nacos.Spec.TLS.Enabled {
+		return base, nil
+	}
+	secret, err := e.k8sService.GetSecret(nacos.Namespace, e.generateTLSSecretName(nacos))
+	if err != nil {
+		return nil, myErrors.New(myErrors.CODE_TLS_NOT_READY, "get secret %s failed: %s", e.generateTLSSecretName(nacos), err.Error())
+	}
+	cli, err := base.WithTLS(secret.Data[TLS_CA_KEY], e.generateAccessAddress(nacos))
+	if err != nil {
+		return nil, myErrors.New(myErrors.CODE_TLS_NOT_READY, "secret %s: %s", secret.Name, err.Error())
+	}
+	return cli, nil
+}
@@ -0,0 +1,180 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
+	"nacos.io/nacos-operator/pkg/service/k8s"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// 删除nacos前按照deletionPolicy处理数据
+const NACOS_FINALIZER = "nacos.io/teardown"
+
+// nacos-mysql.sql创建的表，镜像中没有sql文件，删除数据时以此为准，修改sql时需要同步
+var NACOS_TABLES = []string{
+	"config_info",
+	"config_info_aggr",
+	"config_info_beta",
+	"config_info_tag",
+	"config_tags_relation",
+	"group_capacity",
+	"his_config_info",
+	"tenant_capacity",
+	"tenant_info",
+	"users",
+	"roles",
+	"permissions",
+}
+
+// statefulset的volumeClaimTemplate名称，pvc名称为db-<name>-<ordinal>
+const DATA_VOLUME_NAME = "db"
+
+type ITeardownClient interface {
+	EnsureFinalizer(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error)
+	MakeTeardown(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error)
+}
+
+type TeardownClient struct {
+	k8sService k8s.Services
+	logger     log.Logger
+	client     client.Client
+	kindClient *KindClient
+}
+
+func NewTeardownClient(logger log.Logger, k8sService k8s.Services, client client.Client, kindClient *KindClient) *TeardownClient {
+	return &TeardownClient{
+		k8sService: k8sService,
+		logger:     logger,
+		client:     client,
+		kindClient: kindClient,
+	}
+}
+
+// EnsureFinalizer 添加finalizer，删除cr时先处理数据
+func (c *TeardownClient) EnsureFinalizer(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	if containsString(nacos.Finalizers, NACOS_FINALIZER) {
+		return 0, nil
+	}
+	nacos.Finalizers = append(nacos.Finalizers, NACOS_FINALIZER)
+	return 0, c.client.Update(context.TODO(), nacos)
+}
+
+// MakeTeardown 按照deletionPolicy保留或删除pvc和mysql中的数据，完成后移除finalizer
+func (c *TeardownClient) MakeTeardown(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	if !containsString(nacos.Finalizers, NACOS_FINALIZER) {
+		return 0, nil
+	}
+
+	policy := nacos.Spec.DeletionPolicy
+	if policy == nacosgroupv1alpha1.DeletionPolicySnapshot {
+		succeeded, requeue, err := c.finalBackup(nacos)
+		if err != nil || requeue > 0 {
+			return requeue, err
+		}
+		// 备份失败时保留数据，避免数据丢失
+		if !succeeded {
+			policy = nacosgroupv1alpha1.DeletionPolicyRetain
+		}
+	}
+	if policy == nacosgroupv1alpha1.DeletionPolicyDelete || policy == nacosgroupv1alpha1.DeletionPolicySnapshot {
+		if requeue, err := c.deleteData(nacos); err != nil || requeue > 0 {
+			return requeue, err
+		}
+	} else {
+		c.logger.V(0).Info("retain nacos data", "namespace", nacos.Namespace, "name", nacos.Name)
+	}
+
+	nacos.Finalizers = removeString(nacos.Finalizers, NACOS_FINALIZER)
+	return 0, c.client.Update(context.TODO(), nacos)
+}
+
+// finalBackup 删除前的最后一次备份，返回备份是否成功
+// 备份不设置ownerReference，删除nacos后依然保留
+func (c *TeardownClient) finalBackup(nacos *nacosgroupv1alpha1.Nacos) (bool, time.Duration, error) {
+	name := fmt.Sprintf("%s-final-%s", nacos.Name, nacos.DeletionTimestamp.UTC().Format("20060102-1504"))
+	nacosBackup := &nacosgroupv1alpha1.NacosBackup{}
+	err := c.client.Get(context.TODO(), types.NamespacedName{Namespace: nacos.Namespace, Name: name}, nacosBackup)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, 0, err
+	}
+	if errors.IsNotFound(err) {
+		// 实例异常时无法导出配置
+		if nacos.Spec.FinalBackup == nil || nacos.Status.Phase != nacosgroupv1alpha1.PhaseRunning {
+			c.logger.V(0).Info("skip final backup, nacos is not running", "namespace", nacos.Namespace, "name", nacos.Name,
+				"phase", nacos.Status.Phase)
+			return false, 0, nil
+		}
+		nacosBackup = &nacosgroupv1alpha1.NacosBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: nacos.Namespace,
+			},
+			Spec: nacosgroupv1alpha1.NacosBackupSpec{
+				NacosName: nacos.Name,
+				Storage:   *nacos.Spec.FinalBackup.DeepCopy(),
+			},
+		}
+		if err := c.client.Create(context.TODO(), nacosBackup); err != nil {
+			return false, 0, err
+		}
+		c.logger.V(0).Info("create final backup", "namespace", nacos.Namespace, "name", name)
+		return false, REQUEUE_INTERVAL, nil
+	}
+
+	switch nacosBackup.Status.Phase {
+	case nacosgroupv1alpha1.BackupPhaseSucceeded:
+		return true, 0, nil
+	case nacosgroupv1alpha1.BackupPhaseFailed:
+		c.logger.V(0).Info("final backup failed", "namespace", nacos.Namespace, "name", name, "msg", nacosBackup.Status.Message)
+		return false, 0, nil
+	default:
+		return false, REQUEUE_INTERVAL, nil
+	}
+}
+
+// deleteData mysql模式下删除nacos创建的表，然后删除数据pvc
+func (c *TeardownClient) deleteData(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	if nacos.Spec.Database.TypeDatabase == "mysql" {
+		job, err := c.kindClient.buildMysqlCleanupJob(nacos, NACOS_TABLES)
+		if err != nil {
+			return 0, err
+		}
+		done, msg, err := ensureJob(c.k8sService, job)
+		if err != nil {
+			return 0, err
+		}
+		if !done {
+			return REQUEUE_INTERVAL, nil
+		}
+		// 删除失败时不阻塞cr的删除，记录事件由用户手动处理
+		if msg != "" {
+			c.logger.V(0).Info("mysql cleanup failed", "namespace", nacos.Namespace, "name", nacos.Name, "msg", msg)
+			c.kindClient.recorder.Eventf(nacos, corev1.EventTypeWarning, EVENT_REASON_MYSQL_CLEANUP_FAILED, "mysql cleanup job %s failed, tables are left in place: %s", job.Name, msg)
+		}
+	}
+
+	pvcs, err := c.k8sService.ListPersistentVolumeClaims(nacos.Namespace, c.kindClient.generateLabels(nacos.Name, NACOS))
+	if err != nil {
+		return 0, err
+	}
+	// 缩容后留下的pvc序号可能大于当前副本数，按名称匹配
+	prefix := fmt.Sprintf("%s-%s-", DATA_VOLUME_NAME, c.kindClient.generateName(nacos))
+	for _, pvc := range pvcs.Items {
+		if !strings.HasPrefix(pvc.Name, prefix) {
+			continue
+		}
+		if err := c.k8sService.DeletePersistentVolumeClaim(nacos.Namespace, pvc.Name); err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,30 @@
+package operator
+
+import (
+	"io/ioutil"
+	"regexp"
+	"testing"
+)
+
+var createTable = regexp.MustCompile("(?i)CREATE TABLE\\s+(?:IF NOT EXISTS\\s+)?`?(\\w+)`?")
+
+// NACOS_TABLES需要和nacos-mysql.sql保持一致
+func TestNacosTables(t *testing.T) {
+	bytes, err := ioutil.ReadFile("../../../config/sql/" + SQL_FILE_NAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := []string{}
+	for _, match := range createTable.FindAllStringSubmatch(string(bytes), -1) {
+		tables = append(tables, match[1])
+	}
+	if len(tables) != len(NACOS_TABLES) {
+		t.Fatalf("tables in %s = %v, NACOS_TABLES = %v", SQL_FILE_NAME, tables, NACOS_TABLES)
+	}
+	for i := range tables {
+		if tables[i] != NACOS_TABLES[i] {
+			t.Errorf("tables in %s = %v, NACOS_TABLES = %v", SQL_FILE_NAME, tables, NACOS_TABLES)
+			break
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package operator
 
 import (
+	"time"
+
 	log "github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	nacosgroupv1alpha1 "nacos.io/nacos-operator/api/v1alpha1"
 	myErrors "nacos.io/nacos-operator/pkg/errors"
 	"nacos.io/nacos-operator/pkg/service/k8s"
+	nacosClient "nacos.io/nacos-operator/pkg/service/nacos"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -15,78 +19,190 @@ type IOperatorClient interface {
 	ICheckClient
 	IHealClient
 	IStatusClient
+	IRollingClient
+	IScaleClient
+	IBackupClient
+	IScheduleClient
+	ITeardownClient
+	IResourceClient
+	IAuthClient
 }
 
+// 状态变化后重新入队的间隔
+const REQUEUE_INTERVAL = time.Second * 5
+
 type OperatorClient struct {
-	KindClient   *KindClient
-	CheckClient  *CheckClient
-	HealClient   *HealClient
-	StatusClient *StatusClient
+	KindClient     *KindClient
+	CheckClient    *CheckClient
+	HealClient     *HealClient
+	StatusClient   *StatusClient
+	RollingClient  *RollingClient
+	ScaleClient    *ScaleClient
+	BackupClient   *BackupClient
+	ScheduleClient *ScheduleClient
+	TeardownClient *TeardownClient
+	ResourceClient *ResourceClient
+	AuthClient     *AuthClient
 }
 
-func NewOperatorClient(logger log.Logger, clientset *kubernetes.Clientset, s *runtime.Scheme, client client.Client) *OperatorClient {
+func NewOperatorClient(logger log.Logger, clientset *kubernetes.Clientset, s *runtime.Scheme, client client.Client, nacosTimeout time.Duration, recorder record.EventRecorder) *OperatorClient {
 	service := k8s.NewK8sService(clientset, logger)
+	kindClient := NewKindClient(logger, service, s, client, nacosClient.NewNacosClient(nacosTimeout), recorder)
+	statusClient := NewStatusClient(logger, service, client, recorder)
 	return &OperatorClient{
 		// 资源客户端
-		KindClient: NewKindClient(logger, service, s),
+		KindClient: kindClient,
 		// 检测客户端
-		CheckClient: NewCheckClient(logger, service),
+		CheckClient: NewCheckClient(logger, service, kindClient, recorder),
 		// 状态客户端
-		StatusClient: NewStatusClient(logger, service, client),
+		StatusClient: statusClient,
 		// 维护客户端
-		HealClient: NewHealClient(logger, service),
+		HealClient: NewHealClient(logger, service, kindClient, statusClient),
+		// 滚动更新客户端
+		RollingClient: NewRollingClient(logger, service, kindClient, statusClient),
+		// 扩缩容客户端
+		ScaleClient: NewScaleClient(logger, service, kindClient, statusClient),
+		// 备份恢复客户端
+		BackupClient: NewBackupClient(logger, service, s, client, kindClient, statusClient),
+		// 定时备份客户端
+		ScheduleClient: NewScheduleClient(logger, client),
+		// 删除客户端
+		TeardownClient: NewTeardownClient(logger, service, client, kindClient),
+		// 命名空间、用户、角色、权限、配置客户端
+		ResourceClient: NewResourceClient(logger, service, client, kindClient),
+		// 鉴权客户端
+		AuthClient: NewAuthClient(logger, service, kindClient),
 	}
 }
 
-func (c *OperatorClient) MakeEnsure(nacos *nacosgroupv1alpha1.Nacos) {
+func (c *OperatorClient) MakeEnsure(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
 	// 验证CR字段
-	c.KindClient.ValidationField(nacos)
+	if err := c.KindClient.ValidationField(nacos); err != nil {
+		return 0, err
+	}
 
+	var ensures []func(nacos *nacosgroupv1alpha1.Nacos) error
+	// 鉴权secret需要在statefulset之前准备好
+	ensures = append(ensures, c.KindClient.EnsureAuthSecret)
+	// mysql账号secret需要在statefulset之前准备好
+	if nacos.Spec.Database.TypeDatabase == "mysql" {
+		ensures = append(ensures, c.KindClient.EnsureMysqlSecret)
+	}
+	// 证书需要在statefulset之前签发
+	ensures = append(ensures, c.KindClient.EnsureTLS)
 	switch nacos.Spec.Type {
 	case TYPE_STAND_ALONE:
-		c.KindClient.EnsureConfigmap(nacos)
-		c.KindClient.EnsureStatefulset(nacos)
-		c.KindClient.EnsureService(nacos)
-		if nacos.Spec.Database.TypeDatabase == "mysql" {
-			c.KindClient.EnsureMysqlConfigMap(nacos)
-			c.KindClient.EnsureJob(nacos)
-		}
+		ensures = append(ensures,
+			c.KindClient.EnsureConfigmap,
+			c.KindClient.EnsureStatefulset,
+			c.KindClient.EnsureService,
+		)
 	case TYPE_CLUSTER:
-		c.KindClient.EnsureConfigmap(nacos)
-		c.KindClient.EnsureStatefulsetCluster(nacos)
-		c.KindClient.EnsureHeadlessServiceCluster(nacos)
-		c.KindClient.EnsureClientService(nacos)
-		if nacos.Spec.Database.TypeDatabase == "mysql" {
-			c.KindClient.EnsureMysqlConfigMap(nacos)
-			c.KindClient.EnsureJob(nacos)
-		}
+		ensures = append(ensures,
+			c.KindClient.EnsureConfigmap,
+			c.KindClient.EnsureClusterConfigMap,
+			c.KindClient.EnsureStatefulsetCluster,
+			c.KindClient.EnsureHeadlessServiceCluster,
+			c.KindClient.EnsureClientService,
+		)
 	default:
-		panic(myErrors.New(myErrors.CODE_PARAMETER_ERROR, myErrors.MSG_PARAMETER_ERROT, "nacos.Spec.Type", nacos.Spec.Type))
+		return 0, myErrors.New(myErrors.CODE_PARAMETER_ERROR, myErrors.MSG_PARAMETER_ERROT, "nacos.Spec.Type", nacos.Spec.Type)
+	}
+	if nacos.Spec.Database.TypeDatabase == "mysql" {
+		ensures = append(ensures, c.KindClient.EnsureMysqlConfigMap, c.KindClient.EnsureJob)
+	}
+	ensures = append(ensures, c.KindClient.EnsureMonitoring)
+
+	for _, ensure := range ensures {
+		if err := ensure(nacos); err != nil {
+			return 0, err
+		}
 	}
+	return 0, nil
 }
 
-func (c *OperatorClient) PreCheck(nacos *nacosgroupv1alpha1.Nacos) {
+func (c *OperatorClient) EnsureFinalizer(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	return c.TeardownClient.EnsureFinalizer(nacos)
+}
+
+func (c *OperatorClient) MakeTeardown(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	return c.TeardownClient.MakeTeardown(nacos)
+}
+
+func (c *OperatorClient) PreCheck(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
 	switch nacos.Status.Phase {
 	case nacosgroupv1alpha1.PhaseFailed:
-		// 失败，需要修复
-		c.HealClient.MakeHeal(nacos)
+		// 失败，需要修复。自愈失败时已经记录事件，不影响后续步骤
+		if err := c.HealClient.MakeHeal(nacos); err != nil {
+			c.HealClient.logger.V(0).Info("heal failed", "namespace", nacos.Namespace, "name", nacos.Name, "err", err.Error())
+		}
 	case nacosgroupv1alpha1.PhaseNone:
-		// 初始化
+		// 初始化，保存状态后重新入队
 		nacos.Status.Phase = nacosgroupv1alpha1.PhaseCreating
-		panic(myErrors.New(myErrors.CODE_NORMAL, ""))
+		return REQUEUE_INTERVAL, c.StatusClient.UpdateStatus(nacos)
 	case nacosgroupv1alpha1.PhaseScale:
 	default:
 		// TODO
 	}
+	return 0, nil
+}
+
+func (c *OperatorClient) MakeRolling(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	return c.RollingClient.MakeRolling(nacos)
+}
+
+func (c *OperatorClient) MakeScale(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	return c.ScaleClient.MakeScale(nacos)
 }
 
-func (c *OperatorClient) CheckAndMakeHeal(nacos *nacosgroupv1alpha1.Nacos) {
+func (c *OperatorClient) MakeBackup(nacosBackup *nacosgroupv1alpha1.NacosBackup) (time.Duration, error) {
+	return c.BackupClient.MakeBackup(nacosBackup)
+}
+
+func (c *OperatorClient) MakeRestore(restore *nacosgroupv1alpha1.NacosRestore) (time.Duration, error) {
+	return c.BackupClient.MakeRestore(restore)
+}
+
+func (c *OperatorClient) MakeBackupSchedule(schedule *nacosgroupv1alpha1.NacosBackupSchedule) (time.Duration, error) {
+	return c.ScheduleClient.MakeBackupSchedule(schedule)
+}
+
+func (c *OperatorClient) MakeNamespace(namespace *nacosgroupv1alpha1.NacosNamespace) (time.Duration, error) {
+	return c.ResourceClient.MakeNamespace(namespace)
+}
+
+func (c *OperatorClient) MakeUser(user *nacosgroupv1alpha1.NacosUser) (time.Duration, error) {
+	return c.ResourceClient.MakeUser(user)
+}
+
+func (c *OperatorClient) MakeRole(role *nacosgroupv1alpha1.NacosRole) (time.Duration, error) {
+	return c.ResourceClient.MakeRole(role)
+}
+
+func (c *OperatorClient) MakePermission(permission *nacosgroupv1alpha1.NacosPermission) (time.Duration, error) {
+	return c.ResourceClient.MakePermission(permission)
+}
+
+func (c *OperatorClient) MakeConfig(config *nacosgroupv1alpha1.NacosConfig) (time.Duration, error) {
+	return c.ResourceClient.MakeConfig(config)
+}
+
+func (c *OperatorClient) CheckAndMakeHeal(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
 	// 检查kind
-	pods := c.CheckClient.CheckKind(nacos)
+	pods, err := c.CheckClient.CheckKind(nacos)
+	if err != nil {
+		return 0, err
+	}
 	// 检查nacos
-	c.CheckClient.CheckNacos(nacos, pods)
+	return 0, c.CheckClient.CheckNacos(nacos, pods)
+}
+
+func (c *OperatorClient) MakeAuth(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	return c.AuthClient.MakeAuth(nacos)
 }
 
-func (c *OperatorClient) UpdateStatus(nacos *nacosgroupv1alpha1.Nacos) {
-	c.StatusClient.UpdateStatusRunning(nacos)
+func (c *OperatorClient) UpdateStatus(nacos *nacosgroupv1alpha1.Nacos) (time.Duration, error) {
+	// 恢复正常，清理自愈记录
+	c.HealClient.Reset(nacos)
+	return 0, c.StatusClient.UpdateStatusRunning(nacos)
 }
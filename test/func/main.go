@@ -55,9 +55,9 @@ func main() {
 			return
 		}
 		if leader == "" {
-			leader = svc.Servers[0].ExtendInfo.RaftMetaData.MetaDataMap.NamingPersistentService.Leader
+			leader = svc.Servers[0].ExtendInfo.RaftMetaData.MetaDataMap[nacosClient.RAFT_GROUP_NAMING_PERSISTENT].Leader
 		} else {
-			if leader != svc.Servers[0].ExtendInfo.RaftMetaData.MetaDataMap.NamingPersistentService.Leader {
+			if leader != svc.Servers[0].ExtendInfo.RaftMetaData.MetaDataMap[nacosClient.RAFT_GROUP_NAMING_PERSISTENT].Leader {
 				fmt.Println("leader 不匹配")
 				return
 			}